@@ -1,19 +1,31 @@
 package wuid
 
 import (
+	"context"
 	"errors"
 	"github.com/edwingeng/slog"
 	"github.com/edwingeng/wuid/internal"
+	"time"
 )
 
-// WUID is an extremely fast universal unique identifier generator.
+// WUID is an extremely fast universal unique identifier generator. The methods and the options
+// shared by all the wuid packages are documented in full in package internal.
 type WUID struct {
-	w internal.WUID
+	w *internal.WUID
 }
 
-// NewWUID creates a new WUID instance.
+// NewWUID creates a new WUID instance. It panics if any option is invalid.
 func NewWUID(name string, logger slog.Logger, opts ...Option) *WUID {
-	return &WUID{w: *internal.NewWUID(name, logger, opts...)}
+	return &WUID{w: internal.NewWUID(name, logger, opts...)}
+}
+
+// New creates a new WUID instance in the strict mode. Unlike NewWUID, it returns an *OptionError instead of panicking.
+func New(name string, logger slog.Logger, opts ...Option) (*WUID, error) {
+	w, err := internal.New(name, logger, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &WUID{w: w}, nil
 }
 
 // Next returns a unique identifier.
@@ -21,41 +33,86 @@ func (w *WUID) Next() int64 {
 	return w.w.Next()
 }
 
+// TryNext is like Next, but returns an error instead of panicking.
+func (w *WUID) TryNext() (int64, error) {
+	return w.w.TryNext()
+}
+
+// WaitLoaded blocks until h28 is loaded for the first time, ctx is done or w is closed.
+func (w *WUID) WaitLoaded(ctx context.Context) error {
+	return w.w.WaitLoaded(ctx)
+}
+
+// NextWithTag returns a unique identifier whose low bits are tag. It requires WithStep.
+func (w *WUID) NextWithTag(tag int64) int64 {
+	return w.w.NextWithTag(tag)
+}
+
 type H28Callback func() (h28 int64, cleanUp func(), err error)
 
+type H28Source = internal.H28Source
+
+// H28SourceFunc is an adapter to allow the use of an ordinary function as an H28Source.
+type H28SourceFunc = internal.H28SourceFunc
+
+// NewSource returns an H28Source that invokes cb to acquire a number. When the source is used by
+// a generator, cleanUp is called after the number is verified and applied, or rejected. Otherwise,
+// it is called right after cb returns.
+func NewSource(cb H28Callback) H28Source {
+	return H28SourceFunc(func(ctx context.Context) (int64, error) {
+		if cb == nil {
+			return 0, errors.New("cb cannot be nil")
+		}
+		h28, cleanUp, err := cb()
+		if err != nil {
+			return 0, err
+		}
+		if cleanUp != nil {
+			internal.DeferCleanUp(ctx, cleanUp)
+		}
+		return h28, nil
+	})
+}
+
 // LoadH28WithCallback invokes cb to acquire a number. The number is used as the high 28 bits
 // of all generated numbers. In addition, cb is saved for future renewal.
 func (w *WUID) LoadH28WithCallback(cb H28Callback) error {
-	if cb == nil {
-		return errors.New("cb cannot be nil")
-	}
-
-	h28, cleanUp, err := cb()
-	if err != nil {
-		return err
-	}
-	if cleanUp != nil {
-		defer cleanUp()
-	}
+	return w.w.LoadH28FromSource(NewSource(cb))
+}
 
-	if err = w.w.VerifyH28(h28); err != nil {
-		return err
-	}
+type H28CallbackContext func(ctx context.Context) (h28 int64, cleanUp func(), err error)
 
-	w.w.Reset(h28 << 36)
-	w.w.Infof("<wuid> new h28: %d. name: %s", h28, w.w.Name)
+// NewSourceContext is like NewSource, but passes the context of each acquisition to cb.
+func NewSourceContext(cb H28CallbackContext) H28Source {
+	return H28SourceFunc(func(ctx context.Context) (int64, error) {
+		if cb == nil {
+			return 0, errors.New("cb cannot be nil")
+		}
+		h28, cleanUp, err := cb(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if cleanUp != nil {
+			internal.DeferCleanUp(ctx, cleanUp)
+		}
+		return h28, nil
+	})
+}
 
-	w.w.Lock()
-	defer w.w.Unlock()
+// LoadH28WithCallbackContext is like LoadH28WithCallback, but cb receives a context, which is ctx
+// for the first call and bounded by the renew timeout for renewals.
+func (w *WUID) LoadH28WithCallbackContext(ctx context.Context, cb H28CallbackContext) error {
+	return w.w.LoadH28FromSourceContext(ctx, NewSourceContext(cb))
+}
 
-	if w.w.Renew != nil {
-		return nil
-	}
-	w.w.Renew = func() error {
-		return w.LoadH28WithCallback(cb)
-	}
+// LoadH28FromSource acquires h28 from src, and saves src for future renewal.
+func (w *WUID) LoadH28FromSource(src H28Source) error {
+	return w.w.LoadH28FromSource(src)
+}
 
-	return nil
+// LoadH28FromSourceContext is like LoadH28FromSource, but the first acquisition is bounded by ctx.
+func (w *WUID) LoadH28FromSourceContext(ctx context.Context, src H28Source) error {
+	return w.w.LoadH28FromSourceContext(ctx, src)
 }
 
 // RenewNow reacquires the high 28 bits immediately.
@@ -63,8 +120,75 @@ func (w *WUID) RenewNow() error {
 	return w.w.RenewNow()
 }
 
+type WorkerLeaser = internal.WorkerLeaser
+
+// LeaseWorker claims a free worker ID from leaser on behalf of owner, and keeps it until w is closed.
+func (w *WUID) LeaseWorker(leaser WorkerLeaser, owner string) error {
+	return w.w.LeaseWorker(leaser, owner)
+}
+
+type H28Checker = internal.H28Checker
+type CheckResult = internal.CheckResult
+
+// Check verifies src without consuming an h28, and reports the headroom against the h28 limit.
+func (w *WUID) Check(ctx context.Context, src H28Source) (CheckResult, error) {
+	return w.w.Check(ctx, src)
+}
+
+// SampleH28 makes w read the counter of src every interval until w is closed, for Forecast and the alerts.
+func (w *WUID) SampleH28(src H28Source, interval time.Duration) error {
+	return w.w.SampleH28(src, interval)
+}
+
+// WatchControl makes w apply the commands sent through ch until w is closed.
+func (w *WUID) WatchControl(ch ControlChannel, owner string) error {
+	return w.w.WatchControl(ch, owner)
+}
+
+// Close stops all the background activities of w.
+func (w *WUID) Close() {
+	w.w.Close()
+}
+
+type ParsedID = internal.ParsedID
+
+// Parse decodes a number generated by w.
+func (w *WUID) Parse(id int64) ParsedID {
+	return w.w.Parse(id)
+}
+
+type Forecast = internal.Forecast
+
+// Forecast projects when the h28 space and the current block run out.
+func (w *WUID) Forecast() Forecast {
+	return w.w.Forecast()
+}
+
+// Provenance returns the provenance record of the holder of the block that id was generated from.
+func (w *WUID) Provenance(id int64) (*ProvenanceRecord, error) {
+	return w.w.Provenance(id)
+}
+
+// CreatedAt returns roughly when id was generated.
+func (w *WUID) CreatedAt(id int64) (time.Time, bool) {
+	return w.w.CreatedAt(id)
+}
+
+// IDRanges returns the ranges of the IDs generated by w roughly in between [from, to].
+func (w *WUID) IDRanges(from, to time.Time) []IDRange {
+	return w.w.IDRanges(from, to)
+}
+
+// ShardOf returns the shard that id belongs to. It only makes sense with WithModulo.
+func (w *WUID) ShardOf(id int64) int64 {
+	return w.w.ShardOf(id)
+}
+
 type Option = internal.Option
 
+// OptionError collects all the invalid options passed to New.
+type OptionError = internal.OptionError
+
 // WithH28Verifier adds an extra verifier for the high 28 bits.
 func WithH28Verifier(cb func(h28 int64) error) Option {
 	return internal.WithH28Verifier(cb)
@@ -75,12 +199,121 @@ func WithSection(section int8) Option {
 	return internal.WithSection(section)
 }
 
+// WithSectionBits brands a section ID on each generated number, using the highest bits bits other than the sign bit.
+func WithSectionBits(bits int8, section int64) Option {
+	return internal.WithSectionBits(bits, section)
+}
+
 // WithStep sets the step and the floor for each generated number.
 func WithStep(step int64, floor int64) Option {
 	return internal.WithStep(step, floor)
 }
 
+// WithModulo makes each generated number satisfy id%n == k.
+func WithModulo(n, k int64) Option {
+	return internal.WithModulo(n, k)
+}
+
 // WithObfuscation enables number obfuscation.
 func WithObfuscation(seed int) Option {
 	return internal.WithObfuscation(seed)
 }
+
+// WithTimestamp embeds a coarse timestamp, which is counted in unit since epoch, in each generated number.
+func WithTimestamp(epoch time.Time, unit time.Duration) Option {
+	return internal.WithTimestamp(epoch, unit)
+}
+
+// WithSnowflake switches to the Snowflake layout, whose worker ID is leased by LeaseWorker.
+func WithSnowflake(epoch time.Time, lease time.Duration) Option {
+	return internal.WithSnowflake(epoch, lease)
+}
+
+// WithRenewTimeout sets the timeout of each renewal, which defaults to 5 seconds.
+func WithRenewTimeout(timeout time.Duration) Option {
+	return internal.WithRenewTimeout(timeout)
+}
+
+// WithStrict enables or disables the strict mode. See New for details.
+func WithStrict(strict bool) Option {
+	return internal.WithStrict(strict)
+}
+
+// WithReserve keeps size h28 values acquired ahead of time in the file at path, in case the data source fails.
+func WithReserve(path string, size int, ttl time.Duration) Option {
+	return internal.WithReserve(path, size, ttl)
+}
+
+type IDRange = internal.IDRange
+
+// WithMinID makes VerifyH28 reject any h28 that could produce a number less than min.
+func WithMinID(min int64) Option {
+	return internal.WithMinID(min)
+}
+
+// WithExcludedRanges makes VerifyH28 reject any h28 whose numbers would intersect one of ranges.
+func WithExcludedRanges(ranges ...IDRange) Option {
+	return internal.WithExcludedRanges(ranges...)
+}
+
+type Journal = internal.Journal
+
+// ErrRollback indicates that the data source handed out an h28 that is not greater than the high-water mark.
+var ErrRollback = internal.ErrRollback
+
+// OpenJournal opens the journal at path, and creates it if it does not exist.
+func OpenJournal(path string) (*Journal, error) {
+	return internal.OpenJournal(path)
+}
+
+// WithJournal records every accepted h28 in j, and rejects a rollback of the data source with ErrRollback.
+func WithJournal(j *Journal) Option {
+	return internal.WithJournal(j)
+}
+
+type Event = internal.Event
+type EventKind = internal.EventKind
+
+const (
+	EventRollback = internal.EventRollback
+	EventHeadroom = internal.EventHeadroom
+)
+
+// WithEventHandler sets a function that is called on noteworthy events. It should return quickly.
+func WithEventHandler(handler func(e Event)) Option {
+	return internal.WithEventHandler(handler)
+}
+
+// WithHeadroomAlerts makes w raise an EventHeadroom when the h28 space left drops to one of thresholds.
+func WithHeadroomAlerts(thresholds ...float64) Option {
+	return internal.WithHeadroomAlerts(thresholds...)
+}
+
+type ProvenanceRecord = internal.ProvenanceRecord
+type ProvenanceStore = internal.ProvenanceStore
+
+// ProvenanceFuncs is an adapter to allow the use of ordinary functions as a ProvenanceStore.
+type ProvenanceFuncs = internal.ProvenanceFuncs
+
+// ErrNoProvenance indicates that there is no provenance record for an ID.
+var ErrNoProvenance = internal.ErrNoProvenance
+
+// WithProvenance makes w record in store who takes each h28.
+func WithProvenance(store ProvenanceStore, version string) Option {
+	return internal.WithProvenance(store, version)
+}
+
+type Command = internal.Command
+
+const (
+	CommandRenew  = internal.CommandRenew
+	CommandPause  = internal.CommandPause
+	CommandResume = internal.CommandResume
+)
+
+type ControlState = internal.ControlState
+type ControlAck = internal.ControlAck
+type ControlChannel = internal.ControlChannel
+
+// ControlPollInterval bounds how late a generator may apply a command if a notification is missed.
+const ControlPollInterval = internal.ControlPollInterval
@@ -1,6 +1,7 @@
 package wuid
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/edwingeng/slog"
@@ -38,6 +39,82 @@ func TestWUID_LoadH28WithCallback_Error(t *testing.T) {
 	}
 }
 
+func TestWUID_LoadH28WithCallback_CleanUp(t *testing.T) {
+	w := NewWUID("alpha", dumb)
+	var applied, called bool
+	err := w.LoadH28WithCallback(func() (int64, func(), error) {
+		return 5, func() {
+			called = true
+			applied = w.w.N>>36 == 5
+		}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !called || !applied {
+		t.Fatal("cleanUp should be called after h28 is applied")
+	}
+
+	called = false
+	err = w.LoadH28WithCallback(func() (int64, func(), error) {
+		return 5, func() { called = true }, nil
+	})
+	if err == nil {
+		t.Fatal("LoadH28WithCallback should fail when h28 is the same")
+	}
+	if !called {
+		t.Fatal("cleanUp should be called when h28 is rejected")
+	}
+}
+
+func TestNew(t *testing.T) {
+	w, err := New("callback-strict", dumb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if _, err := w.TryNext(); err == nil {
+		t.Fatal("TryNext should fail before h28 is loaded")
+	}
+
+	var h28 int64
+	cb := func() (int64, func(), error) {
+		return atomic.AddInt64(&h28, 1), nil, nil
+	}
+	if err := w.LoadH28WithCallback(cb); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.TryNext(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWUID_LoadH28WithCallbackContext(t *testing.T) {
+	var h28 int64
+	cb := func(ctx context.Context) (int64, func(), error) {
+		if err := ctx.Err(); err != nil {
+			return 0, nil, err
+		}
+		return atomic.AddInt64(&h28, 1), nil, nil
+	}
+
+	w := NewWUID("alpha", dumb)
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	cancel1()
+	if err := w.LoadH28WithCallbackContext(ctx1, cb); err == nil {
+		t.Fatal("LoadH28WithCallbackContext should fail when ctx is canceled")
+	}
+	if err := w.LoadH28WithCallbackContext(context.Background(), cb); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.RenewNow(); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt64(&w.w.N) != 2<<36 {
+		t.Fatal(`atomic.LoadInt64(&w.w.N) != 2<<36`)
+	}
+}
+
 func TestWUID_LoadH28WithCallback(t *testing.T) {
 	var h28, counter int64
 	done := func() {
@@ -94,6 +171,99 @@ func TestWUID_LoadH28WithCallback_Section(t *testing.T) {
 	}
 }
 
+func TestWUID_LoadH28WithCallback_SectionBits(t *testing.T) {
+	var h28 int64
+	cb := func() (int64, func(), error) {
+		return atomic.AddInt64(&h28, 1), nil, nil
+	}
+
+	w := NewWUID("alpha", dumb, WithSectionBits(8, 200))
+	for i := 0; i < 1000; i++ {
+		err := w.LoadH28WithCallback(cb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := w.Parse(w.Next())
+		if p.Section != 200 || p.H28 != int64(i)+1 {
+			t.Fatalf("Parse does not work as expected. section: %d, h28: %d, i: %d", p.Section, p.H28, i)
+		}
+	}
+}
+
+func TestWUID_LoadH28WithCallback_Modulo(t *testing.T) {
+	var h28 int64
+	cb := func() (int64, func(), error) {
+		return atomic.AddInt64(&h28, 1), nil, nil
+	}
+
+	w := NewWUID("alpha", dumb, WithModulo(12, 7))
+	for i := 0; i < 100; i++ {
+		err := w.LoadH28WithCallback(cb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < 10; j++ {
+			if id := w.Next(); w.ShardOf(id) != 7 || id>>36 != int64(i)+1 {
+				t.Fatalf("WithModulo does not work as expected. id: %x, i: %d", id, i)
+			}
+		}
+	}
+}
+
+func TestWUID_NextWithTag(t *testing.T) {
+	var h28 int64
+	cb := func() (int64, func(), error) {
+		return atomic.AddInt64(&h28, 1), nil, nil
+	}
+
+	w := NewWUID("alpha", dumb, WithStep(8, 0))
+	if err := w.LoadH28WithCallback(cb); err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(0); i < 100; i++ {
+		if p := w.Parse(w.NextWithTag(i % 8)); p.Tag != i%8 || p.L36>>3 != i+1 {
+			t.Fatalf("NextWithTag does not work as expected. i: %d, p: %+v", i, p)
+		}
+	}
+}
+
+func TestWUID_LoadH28WithCallback_Timestamp(t *testing.T) {
+	var h28 int64
+	cb := func() (int64, func(), error) {
+		return atomic.AddInt64(&h28, 1), nil, nil
+	}
+
+	w := NewWUID("alpha", dumb, WithTimestamp(time.Now().Add(-time.Hour*24), time.Hour))
+	for i := 0; i < 100; i++ {
+		err := w.LoadH28WithCallback(cb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := w.Parse(w.Next())
+		if p.H28 != int64(i)+1 || p.L36 != 1 {
+			t.Fatalf("Parse does not work as expected. h28: %d, l36: %d, i: %d", p.H28, p.L36, i)
+		}
+		if d := time.Since(p.Timestamp); d < 0 || d > time.Hour+internal.CoarseClockInterval {
+			t.Fatalf("the timestamp is out of expectation. d: %v", d)
+		}
+	}
+}
+
+func TestWUID_LoadH28WithCallback_Snowflake(t *testing.T) {
+	var h28 int64 = 1023
+	cb := func() (int64, func(), error) {
+		return atomic.AddInt64(&h28, 1), nil, nil
+	}
+
+	w := NewWUID("alpha", dumb, WithSnowflake(time.Now(), time.Minute))
+	if err := w.LoadH28WithCallback(cb); err == nil {
+		t.Fatal("LoadH28WithCallback should fail in the snowflake mode")
+	}
+	if atomic.LoadInt64(&h28) != 1023 {
+		t.Fatal("cb should not be called in the snowflake mode")
+	}
+}
+
 func TestWUID_LoadH28WithCallback_Same(t *testing.T) {
 	cb := func() (int64, func(), error) {
 		return 100, nil, nil
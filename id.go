@@ -0,0 +1,71 @@
+package wuid
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Entity describes a kind of entity, e.g. users or orders.
+type Entity interface {
+	// Prefix returns the prefix of the display form of the IDs, e.g. "usr_".
+	Prefix() string
+}
+
+// ID is an identifier of the entity type T. IDs of different entity types cannot be mixed up.
+type ID[T Entity] int64
+
+// Int64 returns id as an int64.
+func (id ID[T]) Int64() int64 {
+	return int64(id)
+}
+
+// String returns the display form of id, e.g. "usr_1234567890".
+func (id ID[T]) String() string {
+	var t T
+	return t.Prefix() + strconv.FormatInt(int64(id), 10)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (id ID[T]) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (id *ID[T]) UnmarshalText(text []byte) error {
+	v, err := ParseID[T](string(text))
+	if err != nil {
+		return err
+	}
+	*id = v
+	return nil
+}
+
+// ParseID parses the display form of an ID. It fails if the prefix does not belong to T.
+func ParseID[T Entity](s string) (ID[T], error) {
+	var t T
+	prefix := t.Prefix()
+	if !strings.HasPrefix(s, prefix) {
+		return 0, fmt.Errorf("the prefix of %q does not match %q", s, prefix)
+	}
+	v, err := strconv.ParseInt(s[len(prefix):], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return ID[T](v), nil
+}
+
+// Generator generates IDs of the entity type T with any WUID.
+type Generator[T Entity] struct {
+	w WUID
+}
+
+// NewGenerator creates a new Generator that wraps w.
+func NewGenerator[T Entity](w WUID) *Generator[T] {
+	return &Generator[T]{w: w}
+}
+
+// Next returns a unique identifier of the entity type T.
+func (g *Generator[T]) Next() ID[T] {
+	return ID[T](g.w.Next())
+}
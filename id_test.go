@@ -0,0 +1,74 @@
+package wuid
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type user struct{}
+
+func (user) Prefix() string { return "usr_" }
+
+type order struct{}
+
+func (order) Prefix() string { return "ord_" }
+
+type counter int64
+
+func (c *counter) Next() int64 {
+	*c++
+	return int64(*c)
+}
+
+func TestGenerator(t *testing.T) {
+	c := counter(100)
+	g := NewGenerator[user](&c)
+	id := g.Next()
+	if id.Int64() != 101 {
+		t.Fatal(`id.Int64() != 101`)
+	}
+	if id.String() != "usr_101" {
+		t.Fatal(`id.String() != "usr_101"`)
+	}
+}
+
+func TestParseID(t *testing.T) {
+	id, err := ParseID[user]("usr_123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 123 {
+		t.Fatal(`id != 123`)
+	}
+	if _, err := ParseID[order]("usr_123"); err == nil {
+		t.Fatal("ParseID should reject a mismatched prefix")
+	}
+	if _, err := ParseID[user]("usr_abc"); err == nil {
+		t.Fatal("ParseID should reject a malformed ID")
+	}
+}
+
+func TestID_JSON(t *testing.T) {
+	var v struct {
+		User  ID[user]
+		Order ID[order]
+	}
+	v.User, v.Order = 1, 2
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"User":"usr_1","Order":"ord_2"}` {
+		t.Fatalf("unexpected json: %s", data)
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.User != 1 || v.Order != 2 {
+		t.Fatal(`v.User != 1 || v.Order != 2`)
+	}
+	if err := json.Unmarshal([]byte(`{"User":"ord_1"}`), &v); err == nil {
+		t.Fatal("json.Unmarshal should reject a mismatched prefix")
+	}
+}
@@ -0,0 +1,206 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BlockLeaser is implemented by the data sources that are able to lease h28 blocks, so that the unused
+// tail of a block can be resumed after a restart. A block is identified by its h28, and each lease
+// of a block has a fencing token greater than those of the previous leases.
+type BlockLeaser interface {
+	// ClaimBlock takes over the block with the lowest h28 whose lease has expired or is held by owner,
+	// and whose checkpoint is less than below. It bumps the fencing token of the block. ok is false
+	// if there is no such block.
+	ClaimBlock(ctx context.Context, owner string, ttl time.Duration, below int64) (ok bool, h28, checkpoint, token int64, err error)
+	// SaveBlock records checkpoint as the checkpoint of the block h28, and extends the lease to ttl,
+	// if token is still the fencing token of the block. A token of 1 creates the block if it does not
+	// exist. ok is false if the block was taken over by another lease.
+	SaveBlock(ctx context.Context, owner string, h28, token, checkpoint int64, ttl time.Duration) (ok bool, err error)
+	// DropBlock removes the block h28 if token is still its fencing token.
+	DropBlock(ctx context.Context, h28, token int64) error
+}
+
+// BlockWindow is the number of low 36 bits values a checkpoint is ahead of the last issued one.
+// It is the most a crash can waste.
+const BlockWindow int64 = 1 << 24
+
+type blockLease struct {
+	sync.Mutex
+	leaser     BlockLeaser
+	owner      string
+	ttl        time.Duration
+	kick       chan struct{}
+	h28        int64
+	token      int64
+	checkpoint int64
+	claimed    struct{ h28, checkpoint, token int64 }
+}
+
+// LeaseBlocks makes w record the checkpoint of the block of every h28 it loads through leaser, and
+// keep renewing the lease in the background until w is closed. When h28 is loaded for the first time,
+// w resumes a block whose lease has expired or is held by owner, if any, instead of acquiring a new one.
+// Next never passes the recorded checkpoint, so two holders of a block can never overlap. Close records
+// the exact position and releases the lease. owner should survive restarts, e.g. a pod name.
+func (w *WUID) LeaseBlocks(leaser BlockLeaser, owner string, ttl time.Duration) error {
+	if leaser == nil {
+		return errors.New("leaser cannot be nil")
+	}
+	if len(owner) == 0 {
+		return errors.New("owner cannot be empty")
+	}
+	if ttl < time.Second {
+		return errors.New("ttl cannot be less than a second")
+	}
+	if w.Flags&12 != 0 {
+		return errors.New("block leases cannot work with the timestamp mode or the snowflake mode")
+	}
+	if atomic.LoadInt64(&w.N) != 0 {
+		return errors.New("blocks must be leased before h28 is loaded")
+	}
+	if w.blocks != nil {
+		return errors.New("blocks are already leased")
+	}
+
+	w.blocks = &blockLease{leaser: leaser, owner: owner, ttl: ttl, kick: make(chan struct{}, 1)}
+	go w.keepBlockLease()
+	return nil
+}
+
+// claimBlock looks for a block to resume. It returns the h28 of the block, or 0 if there is none.
+func (w *WUID) claimBlock(ctx context.Context) (int64, error) {
+	b := w.blocks
+	b.Lock()
+	defer b.Unlock()
+	if b.h28 != 0 {
+		return 0, nil
+	}
+	ok, h28, checkpoint, token, err := b.leaser.ClaimBlock(ctx, b.owner, b.ttl, CriticalValue)
+	if err != nil || !ok {
+		return 0, err
+	}
+	b.claimed.h28, b.claimed.checkpoint, b.claimed.token = h28, checkpoint, token
+	w.Infof("<wuid> block claimed. name: %s, h28: %d, checkpoint: %d", w.Name, h28, checkpoint)
+	return h28, nil
+}
+
+// enterBlock records the first checkpoint of the block h28 and returns the position to start from.
+func (w *WUID) enterBlock(h28 int64) (int64, error) {
+	b := w.blocks
+	b.Lock()
+	defer b.Unlock()
+
+	var start, token int64 = 0, 1
+	if b.claimed.h28 == h28 {
+		start, token = b.claimed.checkpoint, b.claimed.token
+	}
+	b.claimed.h28 = 0
+
+	ctx1, cancel1 := context.WithTimeout(context.Background(), w.RenewTimeout)
+	defer cancel1()
+	checkpoint := nextCheckpoint(start)
+	ok, err := b.leaser.SaveBlock(ctx1, b.owner, h28, token, checkpoint, b.ttl)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("the block has been taken over by another lease. h28: %d", h28)
+	}
+
+	lo, _ := w.blockRange(h28)
+	atomic.StoreInt64(&w.Checkpoint, lo|checkpoint)
+	if b.h28 != 0 {
+		if err := b.leaser.DropBlock(ctx1, b.h28, b.token); err != nil {
+			w.Warnf("<wuid> failed to drop the block. name: %s, h28: %d, reason: %+v", w.Name, b.h28, err)
+		}
+	}
+	b.h28, b.token, b.checkpoint = h28, token, checkpoint
+	return start, nil
+}
+
+func nextCheckpoint(n int64) int64 {
+	if n+BlockWindow > PanicValue {
+		return PanicValue
+	}
+	return n + BlockWindow
+}
+
+func (w *WUID) kickBlockLease() {
+	select {
+	case w.blocks.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (w *WUID) keepBlockLease() {
+	interval := w.blocks.ttl / 4
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.Done:
+			return
+		case <-ticker.C:
+		case <-w.blocks.kick:
+		}
+		w.saveBlock()
+	}
+}
+
+func (w *WUID) saveBlock() {
+	b := w.blocks
+	b.Lock()
+	defer b.Unlock()
+	if b.h28 == 0 {
+		return
+	}
+
+	checkpoint := nextCheckpoint(atomic.LoadInt64(&w.N) & L36Mask)
+	if checkpoint < b.checkpoint {
+		checkpoint = b.checkpoint
+	}
+	ctx1, cancel1 := context.WithTimeout(context.Background(), w.RenewTimeout)
+	defer cancel1()
+	ok, err := b.leaser.SaveBlock(ctx1, b.owner, b.h28, b.token, checkpoint, b.ttl)
+	switch {
+	case err != nil:
+		w.Warnf("<wuid> failed to save the block checkpoint. name: %s, h28: %d, reason: %+v", w.Name, b.h28, err)
+	case !ok:
+		w.Warnf("<wuid> the block was taken over by another lease. name: %s, h28: %d", w.Name, b.h28)
+		b.h28 = 0
+		go renewImpl(w)
+	default:
+		b.checkpoint = checkpoint
+		lo, _ := w.blockRange(b.h28)
+		atomic.StoreInt64(&w.Checkpoint, lo|checkpoint)
+	}
+}
+
+// releaseBlock records the exact position in the current block and lets the lease expire at once,
+// so that the tail can be resumed immediately.
+func (w *WUID) releaseBlock() {
+	b := w.blocks
+	b.Lock()
+	defer b.Unlock()
+	if b.h28 == 0 {
+		return
+	}
+
+	// Next checks the checkpoint after bumping N, so N must be read again after the checkpoint is lowered.
+	atomic.StoreInt64(&w.Checkpoint, atomic.LoadInt64(&w.N)+1)
+	checkpoint := atomic.LoadInt64(&w.N) & L36Mask
+	if checkpoint > b.checkpoint {
+		checkpoint = b.checkpoint
+	}
+	ctx1, cancel1 := context.WithTimeout(context.Background(), w.RenewTimeout)
+	defer cancel1()
+	if _, err := b.leaser.SaveBlock(ctx1, b.owner, b.h28, b.token, checkpoint, 0); err != nil {
+		w.Warnf("<wuid> failed to release the block. name: %s, h28: %d, reason: %+v", w.Name, b.h28, err)
+	}
+	b.h28 = 0
+}
@@ -0,0 +1,200 @@
+package internal
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeBlock struct {
+	owner      string
+	token      int64
+	checkpoint int64
+	expiresAt  time.Time
+}
+
+type fakeBlockLeaser struct {
+	sync.Mutex
+	blocks map[int64]*fakeBlock
+}
+
+func (l *fakeBlockLeaser) ClaimBlock(ctx context.Context, owner string, ttl time.Duration, below int64) (bool, int64, int64, int64, error) {
+	l.Lock()
+	defer l.Unlock()
+	var found int64
+	for h28, b := range l.blocks {
+		if (b.owner == owner || !time.Now().Before(b.expiresAt)) && b.checkpoint < below && (found == 0 || h28 < found) {
+			found = h28
+		}
+	}
+	if found == 0 {
+		return false, 0, 0, 0, nil
+	}
+	b := l.blocks[found]
+	b.owner, b.token, b.expiresAt = owner, b.token+1, time.Now().Add(ttl)
+	return true, found, b.checkpoint, b.token, nil
+}
+
+func (l *fakeBlockLeaser) SaveBlock(ctx context.Context, owner string, h28, token, checkpoint int64, ttl time.Duration) (bool, error) {
+	l.Lock()
+	defer l.Unlock()
+	b, ok := l.blocks[h28]
+	switch {
+	case !ok && token == 1:
+		if l.blocks == nil {
+			l.blocks = make(map[int64]*fakeBlock)
+		}
+		b = &fakeBlock{token: 1}
+		l.blocks[h28] = b
+	case !ok || b.token != token:
+		return false, nil
+	}
+	b.owner, b.checkpoint, b.expiresAt = owner, checkpoint, time.Now().Add(ttl)
+	return true, nil
+}
+
+func (l *fakeBlockLeaser) DropBlock(ctx context.Context, h28, token int64) error {
+	l.Lock()
+	defer l.Unlock()
+	if b, ok := l.blocks[h28]; ok && b.token == token {
+		delete(l.blocks, h28)
+	}
+	return nil
+}
+
+func TestWUID_LeaseBlocks(t *testing.T) {
+	var h28 int64
+	src := H28SourceFunc(func(ctx context.Context) (int64, error) {
+		return atomic.AddInt64(&h28, 1), nil
+	})
+	leaser := &fakeBlockLeaser{}
+
+	w1 := NewWUID("alpha", nil)
+	if err := w1.LeaseBlocks(leaser, "pod-1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := w1.LoadH28FromSource(src); err != nil {
+		t.Fatal(err)
+	}
+	if v := leaser.blocks[1].checkpoint; v != BlockWindow {
+		t.Fatalf("the first checkpoint should be BlockWindow. checkpoint: %d", v)
+	}
+	for i := 0; i < 100; i++ {
+		w1.Next()
+	}
+	last := w1.Next()
+	w1.Close()
+	if v := leaser.blocks[1].checkpoint; v != last&L36Mask {
+		t.Fatalf("Close should save the exact position. checkpoint: %d", v)
+	}
+	func() {
+		defer func() { _ = recover() }()
+		w1.Next()
+		t.Fatal("Next should have panicked")
+	}()
+
+	// Another instance resumes the block at once.
+	w2 := NewWUID("alpha", nil)
+	if err := w2.LeaseBlocks(leaser, "pod-2", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := w2.LoadH28FromSource(src); err != nil {
+		t.Fatal(err)
+	}
+	if v := w2.Next(); v != last+1 {
+		t.Fatalf("the block is not resumed. v: %d, last: %d", v, last)
+	}
+	if v := leaser.blocks[1].token; v != 2 {
+		t.Fatalf("the fencing token should be 2. token: %d", v)
+	}
+
+	// w3 takes over the block after the lease of w2 expires, so w2 is fenced.
+	leaser.blocks[1].expiresAt = time.Now()
+	w3 := NewWUID("alpha", nil)
+	if err := w3.LeaseBlocks(leaser, "pod-3", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := w3.LoadH28FromSource(src); err != nil {
+		t.Fatal(err)
+	}
+	if v1, v2 := w2.Checkpoint&L36Mask, atomic.LoadInt64(&w3.N)&L36Mask; v1 > v2 {
+		t.Fatalf("w2 and w3 overlap. w2.Checkpoint: %d, w3.N: %d", v1, v2)
+	}
+	w2.saveBlock()
+	for i := 0; i < 100 && atomic.LoadInt64(&w2.N)>>36 == 1; i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+	if v := atomic.LoadInt64(&w2.N) >> 36; v != 2 {
+		t.Fatalf("w2 should have moved to a new block. h28: %d", v)
+	}
+	if _, ok := leaser.blocks[2]; !ok {
+		t.Fatal(`leaser.blocks[2] should exist`)
+	}
+
+	w2.Close()
+	w3.Close()
+}
+
+func TestWUID_LeaseBlocks_LoadAcquiredH28(t *testing.T) {
+	src := H28SourceFunc(func(ctx context.Context) (int64, error) {
+		return 0, nil
+	})
+	leaser := &fakeBlockLeaser{}
+
+	w1 := NewWUID("alpha", nil)
+	if err := w1.LeaseBlocks(leaser, "pod-1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := w1.LoadAcquiredH28(context.Background(), src, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	w1.Next()
+	last := w1.Next()
+	w1.Close()
+
+	// A batch load resumes the block instead of using the h28 acquired along with the others.
+	w2 := NewWUID("alpha", nil)
+	defer w2.Close()
+	if err := w2.LeaseBlocks(leaser, "pod-2", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := w2.LoadAcquiredH28(context.Background(), src, 2, nil); err != nil {
+		t.Fatal(err)
+	}
+	if v := w2.Next(); v != last+1 {
+		t.Fatalf("the block is not resumed. v: %d, last: %d", v, last)
+	}
+}
+
+func TestWUID_LeaseBlocks_Checkpoint(t *testing.T) {
+	w := NewWUID("alpha", nil)
+	leaser := &fakeBlockLeaser{}
+	if err := w.LeaseBlocks(leaser, "pod-1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.LoadH28(1, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	atomic.StoreInt64(&w.N, 1<<36|BlockWindow-1)
+	func() {
+		defer func() { _ = recover() }()
+		w.Next()
+		t.Fatal("Next should have panicked")
+	}()
+	for i := 0; i < 100 && atomic.LoadInt64(&w.Checkpoint) == 1<<36|BlockWindow; i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+	atomic.StoreInt64(&w.N, 1<<36|BlockWindow-1)
+	w.Next()
+
+	if err := NewWUID("alpha", nil).LeaseBlocks(nil, "pod-1", time.Minute); err == nil {
+		t.Fatal("LeaseBlocks should fail when leaser is nil")
+	}
+	if err := w.LeaseBlocks(leaser, "pod-1", time.Minute); err == nil {
+		t.Fatal("LeaseBlocks should fail after h28 is loaded")
+	}
+}
@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// H28Checker is implemented by the data sources that are able to verify themselves without consuming
+// an h28, e.g. in readiness probes and deploy checks.
+type H28Checker interface {
+	// Check verifies the connectivity, the schema and the privileges that Acquire needs, and returns
+	// the current value of the counter, i.e. the last h28 handed out, or 0 if there is none.
+	Check(ctx context.Context) (current int64, err error)
+}
+
+// CheckResult is what Check reports.
+type CheckResult struct {
+	Source  string
+	Current int64
+	Limit   int64
+	// Headroom is the fraction of the h28 space left.
+	Headroom float64
+}
+
+// Check verifies src with the current options of w without consuming an h28, and reports the
+// headroom against the h28 limit. src must be an H28Checker. It fails if the h28 space has run out.
+// The current value of the counter is also recorded for Forecast, so src should be the data source of w.
+func (w *WUID) Check(ctx context.Context, src H28Source) (CheckResult, error) {
+	if src == nil {
+		return CheckResult{}, errors.New("src cannot be nil")
+	}
+	c, ok := src.(H28Checker)
+	if !ok {
+		return CheckResult{}, fmt.Errorf("%s does not support Check", describeSource(src))
+	}
+
+	current, err := c.Check(ctx)
+	if err != nil {
+		return CheckResult{}, err
+	}
+	r := CheckResult{
+		Source:  describeSource(src),
+		Current: current,
+		Limit:   w.h28Limit(),
+	}
+	if current > 0 {
+		w.observeH28(current)
+	}
+	r.Headroom = float64(r.Limit-current) / float64(r.Limit)
+	if current >= r.Limit {
+		r.Headroom = 0
+		return r, fmt.Errorf("the h28 space has run out. current: %d, limit: %d", current, r.Limit)
+	}
+	return r, nil
+}
+
+// checkAll checks sources concurrently and returns their current values.
+func checkAll(ctx context.Context, kind string, sources []H28Source) ([]int64, error) {
+	values := make([]int64, len(sources))
+	errs := make([]error, len(sources))
+	var wg sync.WaitGroup
+	for i, src := range sources {
+		wg.Add(1)
+		go func(i int, src H28Source) {
+			defer wg.Done()
+			c, ok := src.(H28Checker)
+			if !ok {
+				errs[i] = fmt.Errorf("%s does not support Check", describeSource(src))
+				return
+			}
+			values[i], errs[i] = c.Check(ctx)
+		}(i, src)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("%s source #%d failed the check: %w", kind, i, err)
+		}
+	}
+	return values, nil
+}
+
+// Check checks all the sources. It fails if any of them fails, because a failover is only as good
+// as the sources behind it.
+func (fs *FailoverSource) Check(ctx context.Context) (int64, error) {
+	values, err := checkAll(ctx, "failover", fs.sources)
+	if err != nil {
+		return 0, err
+	}
+	n := int64(len(values))
+	var current int64
+	for i, v := range values {
+		if h := v*n + int64(i); v > 0 && h > current {
+			current = h
+		}
+	}
+	return current, nil
+}
+
+// Check checks all the sources and returns the maximum of their counters. It fails if any of them fails.
+func (qs *QuorumSource) Check(ctx context.Context) (int64, error) {
+	a := make([]H28Source, len(qs.sources))
+	for i, src := range qs.sources {
+		a[i] = src
+	}
+	return checkMax(ctx, "quorum", a)
+}
+
+// Check checks all the sources and returns the maximum of their counters. It fails if any of them fails.
+func (ps *PartitionedSource) Check(ctx context.Context) (int64, error) {
+	return checkMax(ctx, "partitioned", ps.sources)
+}
+
+func checkMax(ctx context.Context, kind string, sources []H28Source) (int64, error) {
+	values, err := checkAll(ctx, kind, sources)
+	if err != nil {
+		return 0, err
+	}
+	var current int64
+	for _, v := range values {
+		if v > current {
+			current = v
+		}
+	}
+	return current, nil
+}
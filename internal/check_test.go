@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"context"
+	"testing"
+)
+
+func (a *fakeAdvancer) Check(ctx context.Context) (int64, error) {
+	a.Lock()
+	defer a.Unlock()
+	if a.down {
+		return 0, context.DeadlineExceeded
+	}
+	return a.n, nil
+}
+
+func TestWUID_Check(t *testing.T) {
+	w := NewWUID("alpha", nil)
+	a := &fakeAdvancer{n: 0x07FFFFFF / 4 * 3}
+	r, err := w.Check(context.Background(), a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Current != a.n || r.Limit != 0x07FFFFFF {
+		t.Fatalf("wrong check result. current: %d, limit: %d", r.Current, r.Limit)
+	}
+	if r.Headroom < 0.24 || r.Headroom > 0.26 {
+		t.Fatalf("r.Headroom should be about 0.25. headroom: %f", r.Headroom)
+	}
+	if a.n != 0x07FFFFFF/4*3 {
+		t.Fatal("Check should not consume an h28")
+	}
+
+	a.n = 0x07FFFFFF
+	if _, err := w.Check(context.Background(), a); err == nil {
+		t.Fatal("Check should fail when the h28 space has run out")
+	}
+	a.down = true
+	if _, err := w.Check(context.Background(), a); err == nil {
+		t.Fatal("Check should fail when the source is down")
+	}
+
+	f := H28SourceFunc(func(ctx context.Context) (int64, error) { return 1, nil })
+	if _, err := w.Check(context.Background(), f); err == nil {
+		t.Fatal("Check should fail when the source is not an H28Checker")
+	}
+}
+
+func TestCompositeSource_Check(t *testing.T) {
+	a1, a2 := &fakeAdvancer{n: 3}, &fakeAdvancer{n: 5}
+	if current, err := NewFailoverSource(nil, a1, a2).Check(context.Background()); err != nil || current != 11 {
+		t.Fatalf("current should be 11. current: %d, err: %v", current, err)
+	}
+	if current, err := NewQuorumSource(nil, a1, a2).Check(context.Background()); err != nil || current != 5 {
+		t.Fatalf("current should be 5. current: %d, err: %v", current, err)
+	}
+	if current, err := NewPartitionedSource(nil, a1, a2).Check(context.Background()); err != nil || current != 5 {
+		t.Fatalf("current should be 5. current: %d, err: %v", current, err)
+	}
+
+	a2.down = true
+	if _, err := NewQuorumSource(nil, a1, a2).Check(context.Background()); err == nil {
+		t.Fatal("Check should fail if any source fails")
+	}
+}
@@ -0,0 +1,168 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Command is an instruction that operators send to all the generators watching a control channel.
+type Command int
+
+const (
+	// CommandRenew makes the generators renew h28 immediately.
+	CommandRenew Command = iota + 1
+	// CommandPause makes Next panic and TryNext fail until CommandResume is sent.
+	CommandPause
+	// CommandResume undoes CommandPause.
+	CommandResume
+)
+
+func (c Command) String() string {
+	switch c {
+	case CommandRenew:
+		return "renew"
+	case CommandPause:
+		return "pause"
+	case CommandResume:
+		return "resume"
+	default:
+		return "unknown"
+	}
+}
+
+// ControlState is what a control channel holds. Each CommandRenew bumps RenewSeq.
+type ControlState struct {
+	RenewSeq int64
+	Paused   bool
+}
+
+// ControlAck reports the state that a generator has applied.
+type ControlAck struct {
+	Owner    string
+	RenewSeq int64
+	Paused   bool
+	// Err is the reason why the last renewal failed, if any.
+	Err  string
+	Time time.Time
+}
+
+// ControlChannel delivers commands from operators to generators, and acknowledgements back.
+type ControlChannel interface {
+	// Send applies cmd to the state of the channel and notifies the watchers.
+	Send(ctx context.Context, cmd Command) error
+	// State returns the current state of the channel. It returns a zero state if nothing was ever sent.
+	State(ctx context.Context) (ControlState, error)
+	// Wait blocks until the state may have changed or ctx is done. A channel that cannot notify
+	// its watchers simply waits for ctx, so that the state is polled.
+	Wait(ctx context.Context) error
+	// Ack records ack, replacing the previous one of the same owner.
+	Ack(ctx context.Context, ack ControlAck) error
+	// Acks returns the latest acknowledgement of every owner.
+	Acks(ctx context.Context) ([]ControlAck, error)
+}
+
+// ControlPollInterval bounds how late a generator may apply a command if a notification is missed.
+const ControlPollInterval = time.Second * 5
+
+// WatchControl makes w apply the commands sent through ch until w is closed, and acknowledge them
+// on behalf of owner. The current state is applied before it returns, so a paused channel pauses w
+// at once, whereas the renewals requested before are ignored.
+func (w *WUID) WatchControl(ch ControlChannel, owner string) error {
+	if ch == nil {
+		return errors.New("ch cannot be nil")
+	}
+	if len(owner) == 0 {
+		return errors.New("owner cannot be empty")
+	}
+
+	ctx1, cancel1 := context.WithTimeout(context.Background(), w.RenewTimeout)
+	defer cancel1()
+	state, err := ch.State(ctx1)
+	if err != nil {
+		return err
+	}
+	w.applyControl(ch, owner, state, nil)
+	go w.watchControl(ch, owner, state)
+	return nil
+}
+
+func (w *WUID) watchControl(ch ControlChannel, owner string, last ControlState) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-w.Done
+		cancel()
+	}()
+
+	for {
+		ctx1, cancel1 := context.WithTimeout(ctx, ControlPollInterval)
+		if err := ch.Wait(ctx1); err != nil {
+			w.Warnf("<wuid> failed to wait for the control channel. name: %s, reason: %+v", w.Name, err)
+			<-ctx1.Done()
+		}
+		cancel1()
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		ctx2, cancel2 := context.WithTimeout(context.Background(), w.RenewTimeout)
+		state, err := ch.State(ctx2)
+		cancel2()
+		switch {
+		case err != nil:
+			w.Warnf("<wuid> failed to read the control channel. name: %s, reason: %+v", w.Name, err)
+		case state != last:
+			w.applyControl(ch, owner, state, &last)
+			last = state
+		}
+	}
+}
+
+// applyControl applies state, and renews h28 if the renewal sequence has moved on since last.
+func (w *WUID) applyControl(ch ControlChannel, owner string, state ControlState, last *ControlState) {
+	ack := ControlAck{Owner: owner, RenewSeq: state.RenewSeq, Paused: state.Paused}
+	if state.Paused {
+		w.halt(HaltPaused)
+	} else {
+		w.unhalt(HaltPaused)
+	}
+	switch {
+	case last == nil || last.Paused == state.Paused:
+	case state.Paused:
+		w.Warnf("<wuid> the generation is paused by the control channel. name: %s", w.Name)
+	default:
+		w.Warnf("<wuid> the generation is resumed by the control channel. name: %s", w.Name)
+	}
+	if last != nil && state.RenewSeq > last.RenewSeq {
+		w.Warnf("<wuid> renewal requested by the control channel. name: %s", w.Name)
+		if err := w.renewByControl(); err != nil {
+			w.Warnf("<wuid> renew failed. name: %s, reason: %+v", w.Name, err)
+			ack.Err = err.Error()
+		}
+	}
+
+	ack.Time = time.Now()
+	ctx1, cancel1 := context.WithTimeout(context.Background(), w.RenewTimeout)
+	defer cancel1()
+	if err := ch.Ack(ctx1, ack); err != nil {
+		w.Warnf("<wuid> failed to acknowledge the control channel. name: %s, reason: %+v", w.Name, err)
+	}
+}
+
+func (w *WUID) renewByControl() error {
+	w.Lock()
+	f := w.Renew
+	w.Unlock()
+	if f == nil {
+		return errors.New("h28 has not been loaded yet")
+	}
+	atomic.AddInt64(&w.Stats.NumRenewAttempts, 1)
+	if err := f(); err != nil {
+		return err
+	}
+	atomic.AddInt64(&w.Stats.NumRenewed, 1)
+	return nil
+}
@@ -0,0 +1,135 @@
+package internal
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeControlChannel struct {
+	sync.Mutex
+	state  ControlState
+	acks   map[string]ControlAck
+	notify chan struct{}
+}
+
+func newFakeControlChannel() *fakeControlChannel {
+	return &fakeControlChannel{acks: make(map[string]ControlAck), notify: make(chan struct{}, 1)}
+}
+
+func (c *fakeControlChannel) Send(ctx context.Context, cmd Command) error {
+	c.Lock()
+	switch cmd {
+	case CommandRenew:
+		c.state.RenewSeq++
+	case CommandPause:
+		c.state.Paused = true
+	case CommandResume:
+		c.state.Paused = false
+	}
+	c.Unlock()
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (c *fakeControlChannel) State(ctx context.Context) (ControlState, error) {
+	c.Lock()
+	defer c.Unlock()
+	return c.state, nil
+}
+
+func (c *fakeControlChannel) Wait(ctx context.Context) error {
+	select {
+	case <-c.notify:
+	case <-ctx.Done():
+	}
+	return nil
+}
+
+func (c *fakeControlChannel) Ack(ctx context.Context, ack ControlAck) error {
+	c.Lock()
+	defer c.Unlock()
+	c.acks[ack.Owner] = ack
+	return nil
+}
+
+func (c *fakeControlChannel) Acks(ctx context.Context) ([]ControlAck, error) {
+	c.Lock()
+	defer c.Unlock()
+	var acks []ControlAck
+	for _, ack := range c.acks {
+		acks = append(acks, ack)
+	}
+	return acks, nil
+}
+
+func (c *fakeControlChannel) ack(owner string) ControlAck {
+	c.Lock()
+	defer c.Unlock()
+	return c.acks[owner]
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for i := 0; i < 200; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	t.Fatal("timeout")
+}
+
+func TestWUID_WatchControl(t *testing.T) {
+	var h28 int64
+	src := H28SourceFunc(func(ctx context.Context) (int64, error) {
+		return atomic.AddInt64(&h28, 1), nil
+	})
+	ctx := context.Background()
+	ch := newFakeControlChannel()
+	_ = ch.Send(ctx, CommandRenew)
+	_ = ch.Send(ctx, CommandPause)
+	<-ch.notify
+
+	w := NewWUID("alpha", nil)
+	defer w.Close()
+	if err := w.LoadH28FromSource(src); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WatchControl(ch, "pod-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.TryNext(); err == nil {
+		t.Fatal("w should have been paused")
+	}
+	if ack := ch.ack("pod-1"); !ack.Paused || ack.RenewSeq != 1 {
+		t.Fatalf("unexpected ack: %+v", ack)
+	}
+	if v := atomic.LoadInt64(&h28); v != 1 {
+		t.Fatal("the renewals requested before should be ignored")
+	}
+
+	_ = ch.Send(ctx, CommandResume)
+	waitFor(t, func() bool { return !ch.ack("pod-1").Paused })
+	if _, err := w.TryNext(); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = ch.Send(ctx, CommandRenew)
+	waitFor(t, func() bool { return ch.ack("pod-1").RenewSeq == 2 })
+	if v := atomic.LoadInt64(&w.N) >> 36; v != 2 {
+		t.Fatalf("h28 should have been renewed. h28: %d", v)
+	}
+	if ack := ch.ack("pod-1"); ack.Err != "" {
+		t.Fatalf("unexpected ack: %+v", ack)
+	}
+
+	if err := w.WatchControl(nil, "pod-1"); err == nil {
+		t.Fatal("WatchControl should fail when ch is nil")
+	}
+}
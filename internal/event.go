@@ -0,0 +1,45 @@
+package internal
+
+type EventKind int
+
+const (
+	// EventRollback is raised when h28 is not greater than the high-water mark in the journal.
+	EventRollback EventKind = iota + 1
+	// EventHeadroom is raised when the fraction of the h28 space left drops to a threshold.
+	EventHeadroom
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventRollback:
+		return "rollback"
+	case EventHeadroom:
+		return "headroom"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes something noteworthy that happened to a generator.
+type Event struct {
+	Kind EventKind
+	Name string
+	H28  int64
+	Err  error
+	// Headroom is the fraction of the h28 space left. It is only set for EventHeadroom.
+	Headroom float64
+}
+
+// Emit passes e to the event handler, if any.
+func (w *WUID) Emit(e Event) {
+	if w.EventHandler != nil {
+		w.EventHandler(e)
+	}
+}
+
+// WithEventHandler sets a function that is called on noteworthy events. It should return quickly.
+func WithEventHandler(handler func(e Event)) Option {
+	return func(w *WUID) {
+		w.EventHandler = handler
+	}
+}
@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/edwingeng/slog"
+	"sync/atomic"
+	"time"
+)
+
+// FailoverSource tries a list of sources in order until one of them succeeds. The h28 space is
+// partitioned among the sources: a number h acquired from the i-th source of n becomes h*n+i,
+// so the numbers of different sources never collide.
+type FailoverSource struct {
+	logger  slog.Logger
+	sources []H28Source
+	stats   []FailoverSourceStats
+	last    int64
+
+	numFailovers int64
+}
+
+// FailoverSourceStats is the statistics of a single source of a FailoverSource.
+type FailoverSourceStats struct {
+	NumAcquired int64
+	NumFailed   int64
+}
+
+// FailoverStats is the statistics of a FailoverSource.
+type FailoverStats struct {
+	// Current is the index of the source that succeeded last time.
+	Current      int
+	NumFailovers int64
+	Sources      []FailoverSourceStats
+}
+
+func NewFailoverSource(logger slog.Logger, sources ...H28Source) *FailoverSource {
+	if len(sources) == 0 {
+		panic("sources cannot be empty")
+	}
+	for _, src := range sources {
+		if src == nil {
+			panic("sources cannot contain nil")
+		}
+	}
+	if logger == nil {
+		logger = slog.NewDevelopmentConfig().MustBuild()
+	}
+	return &FailoverSource{
+		logger:  logger,
+		sources: append([]H28Source(nil), sources...),
+		stats:   make([]FailoverSourceStats, len(sources)),
+	}
+}
+
+func (fs *FailoverSource) Acquire(ctx context.Context) (int64, error) {
+	n := int64(len(fs.sources))
+	var lastErr error
+	for i, src := range fs.sources {
+		h, err := acquireWithin(ctx, src, len(fs.sources)-i)
+		if err == nil && (h <= 0 || h > (0x07FFFFFF-int64(i))/n) {
+			err = fmt.Errorf("h28 is out of the partition range. h28: %d", h)
+		}
+		if err != nil {
+			atomic.AddInt64(&fs.stats[i].NumFailed, 1)
+			fs.logger.Warnf("<wuid> source #%d failed. reason: %+v", i, err)
+			lastErr = err
+			continue
+		}
+
+		atomic.AddInt64(&fs.stats[i].NumAcquired, 1)
+		if last := atomic.SwapInt64(&fs.last, int64(i)); last != int64(i) {
+			atomic.AddInt64(&fs.numFailovers, 1)
+			fs.logger.Warnf("<wuid> failed over from source #%d to source #%d", last, i)
+		}
+		return h*n + int64(i), nil
+	}
+	return 0, fmt.Errorf("all the sources failed. last error: %w", lastErr)
+}
+
+func (fs *FailoverSource) String() string {
+	return describeSources("failover", fs.sources)
+}
+
+// acquireWithin gives src an even share of the time left among the remaining sources, so that
+// a hanging source cannot use up the time of the sources after it.
+func acquireWithin(ctx context.Context, src H28Source, remaining int) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return src.Acquire(ctx)
+	}
+	d := time.Until(deadline) / time.Duration(remaining)
+	if d <= 0 {
+		return 0, errors.New("no time left")
+	}
+	ctx1, cancel1 := context.WithTimeout(ctx, d)
+	defer cancel1()
+	return src.Acquire(ctx1)
+}
+
+// Stats returns the statistics of fs.
+func (fs *FailoverSource) Stats() (stats FailoverStats) {
+	stats.Current = int(atomic.LoadInt64(&fs.last))
+	stats.NumFailovers = atomic.LoadInt64(&fs.numFailovers)
+	stats.Sources = make([]FailoverSourceStats, len(fs.stats))
+	for i := range fs.stats {
+		stats.Sources[i].NumAcquired = atomic.LoadInt64(&fs.stats[i].NumAcquired)
+		stats.Sources[i].NumFailed = atomic.LoadInt64(&fs.stats[i].NumFailed)
+	}
+	return
+}
@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFailoverSource(t *testing.T) {
+	var down int32
+	var h1, h2 int64
+	src1 := H28SourceFunc(func(ctx context.Context) (int64, error) {
+		if atomic.LoadInt32(&down) != 0 {
+			return 0, errors.New("down")
+		}
+		return atomic.AddInt64(&h1, 1), nil
+	})
+	src2 := H28SourceFunc(func(ctx context.Context) (int64, error) {
+		return atomic.AddInt64(&h2, 1), nil
+	})
+
+	fs := NewFailoverSource(nil, src1, src2)
+	seen := make(map[int64]struct{})
+	acquire := func(expected int64) {
+		t.Helper()
+		h28, err := fs.Acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h28%2 != expected {
+			t.Fatalf("h28 is not from source #%d. h28: %d", expected, h28)
+		}
+		if _, ok := seen[h28]; ok {
+			t.Fatalf("duplicate h28: %d", h28)
+		}
+		seen[h28] = struct{}{}
+	}
+
+	acquire(0)
+	atomic.StoreInt32(&down, 1)
+	acquire(1)
+	acquire(1)
+	atomic.StoreInt32(&down, 0)
+	acquire(0)
+
+	stats := fs.Stats()
+	if stats.Current != 0 || stats.NumFailovers != 2 {
+		t.Fatalf("Stats does not work as expected. stats: %+v", stats)
+	}
+	if stats.Sources[0].NumAcquired != 2 || stats.Sources[0].NumFailed != 2 || stats.Sources[1].NumAcquired != 2 {
+		t.Fatalf("Stats does not work as expected. stats: %+v", stats)
+	}
+}
+
+func TestFailoverSource_Error(t *testing.T) {
+	hang := H28SourceFunc(func(ctx context.Context) (int64, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	huge := H28SourceFunc(func(ctx context.Context) (int64, error) {
+		return 0x07FFFFFF, nil
+	})
+	ok := H28SourceFunc(func(ctx context.Context) (int64, error) {
+		return 1, nil
+	})
+
+	fs := NewFailoverSource(nil, hang, huge, ok)
+	ctx1, cancel1 := context.WithTimeout(context.Background(), time.Millisecond*300)
+	defer cancel1()
+	h28, err := fs.Acquire(ctx1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h28 != 1*3+2 {
+		t.Fatalf("h28 should be 5. h28: %d", h28)
+	}
+
+	fs = NewFailoverSource(nil, hang, huge)
+	if _, err := fs.Acquire(ctx1); err == nil {
+		t.Fatal("Acquire should fail when all the sources fail")
+	}
+
+	func() {
+		defer func() {
+			_ = recover()
+		}()
+		NewFailoverSource(nil)
+		t.Fatal("NewFailoverSource should have panicked")
+	}()
+}
@@ -0,0 +1,208 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Forecast projects when the h28 space and the current block run out. A zero time means that
+// there is not enough history yet.
+type Forecast struct {
+	H28      int64
+	H28Limit int64
+	// H28Rate is the number of h28 values consumed per hour by all the generators sharing the data source.
+	H28Rate          float64
+	H28ExhaustedAt   time.Time
+	BlockExhaustedAt time.Time
+}
+
+const forecastHistory = 16
+
+type h28Sample struct {
+	h28 int64
+	at  time.Time
+}
+
+type forecastState struct {
+	sync.Mutex
+	samples       []h28Sample
+	blockStart    int64
+	blockLoadedAt time.Time
+	alerted       int
+}
+
+func (w *WUID) h28Limit() int64 {
+	switch {
+	case w.Flags&4 != 0:
+		return 0x000FFFFF
+	case w.Monolithic:
+		return 0x07FFFFFF
+	default:
+		return w.sectionH28Limit()
+	}
+}
+
+// trackH28 records the consumption of h28 loaded by w.
+func (w *WUID) trackH28(h28 int64) {
+	if w.Flags&8 != 0 {
+		return
+	}
+
+	f := &w.forecast
+	f.Lock()
+	f.blockStart = atomic.LoadInt64(&w.N) & L36Mask
+	f.blockLoadedAt = time.Now()
+	f.Unlock()
+	w.observeH28(h28)
+}
+
+// observeH28 records h28 as the current value of the shared counter and raises a headroom alert
+// if a threshold is crossed.
+func (w *WUID) observeH28(h28 int64) {
+	if w.Flags&8 != 0 {
+		return
+	}
+
+	f := &w.forecast
+	f.Lock()
+	if k := len(f.samples); k > 0 && h28 < f.samples[k-1].h28 {
+		// The loads and the samples may interleave. The counter never goes back.
+		h28 = f.samples[k-1].h28
+	}
+	if len(f.samples) == forecastHistory {
+		f.samples = append(f.samples[:0], f.samples[1:]...)
+	}
+	f.samples = append(f.samples, h28Sample{h28: h28, at: time.Now()})
+
+	limit := w.h28Limit()
+	headroom := float64(limit-h28) / float64(limit)
+	crossed := f.alerted
+	for crossed < len(w.HeadroomThresholds) && headroom <= w.HeadroomThresholds[crossed] {
+		crossed++
+	}
+	if crossed == f.alerted {
+		f.Unlock()
+		return
+	}
+	f.alerted = crossed
+	f.Unlock()
+
+	fc := w.Forecast()
+	if fc.H28ExhaustedAt.IsZero() {
+		w.Warnf("<wuid> the h28 space is running out. name: %s, h28: %d, limit: %d, headroom: %.2f%%",
+			w.Name, h28, limit, headroom*100)
+	} else {
+		w.Warnf("<wuid> the h28 space is running out. name: %s, h28: %d, limit: %d, headroom: %.2f%%, exhausted at: %s",
+			w.Name, h28, limit, headroom*100, fc.H28ExhaustedAt.Format(time.RFC3339))
+	}
+	w.Emit(Event{Kind: EventHeadroom, Name: w.Name, H28: h28, Headroom: headroom})
+}
+
+// Forecast projects when the h28 space and the current block run out, based on the h28 values
+// loaded recently and the numbers generated since the last load. The h28 rate only follows the loads
+// of w unless the shared counter is sampled by SampleH28 or Check. It does not work in the snowflake mode.
+func (w *WUID) Forecast() Forecast {
+	var fc Forecast
+	if w.Flags&8 != 0 {
+		return fc
+	}
+
+	f := &w.forecast
+	f.Lock()
+	defer f.Unlock()
+	if len(f.samples) == 0 {
+		return fc
+	}
+
+	now := time.Now()
+	first, last := f.samples[0], f.samples[len(f.samples)-1]
+	fc.H28 = last.h28
+	fc.H28Limit = w.h28Limit()
+	if d := last.at.Sub(first.at); d > 0 && last.h28 > first.h28 {
+		fc.H28Rate = float64(last.h28-first.h28) / d.Hours()
+		left := float64(fc.H28Limit-last.h28) / fc.H28Rate
+		fc.H28ExhaustedAt = last.at.Add(toDuration(left * float64(time.Hour)))
+	}
+
+	if w.Flags&4 == 0 {
+		used := atomic.LoadInt64(&w.N)&L36Mask - f.blockStart
+		// The samples taken by Check do not restart the block, so the rate is measured from the load.
+		if d := now.Sub(f.blockLoadedAt); d > 0 && used > 0 {
+			rate := float64(used) / float64(d)
+			left := float64(PanicValue-f.blockStart-used) / rate
+			fc.BlockExhaustedAt = now.Add(toDuration(left))
+		}
+	}
+	return fc
+}
+
+// SampleH28 makes w read the counter of src every interval in the background until w is closed,
+// so that Forecast and the headroom alerts follow the h28 values consumed by all the generators
+// sharing src, rather than the loads of w alone. src must be an H28Checker.
+func (w *WUID) SampleH28(src H28Source, interval time.Duration) error {
+	if src == nil {
+		return errors.New("src cannot be nil")
+	}
+	if _, ok := src.(H28Checker); !ok {
+		return fmt.Errorf("%s does not support Check", describeSource(src))
+	}
+	if interval < time.Second {
+		return errors.New("interval cannot be less than a second")
+	}
+	if w.Flags&8 != 0 {
+		return errors.New("the forecast does not work in the snowflake mode")
+	}
+	go w.sampleH28(src, interval)
+	return nil
+}
+
+func (w *WUID) sampleH28(src H28Source, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ctx1, cancel1 := context.WithTimeout(context.Background(), w.RenewTimeout)
+		_, err := w.Check(ctx1, src)
+		cancel1()
+		if err != nil {
+			w.Warnf("<wuid> failed to sample h28. name: %s, source: %s, reason: %+v", w.Name, describeSource(src), err)
+		}
+
+		select {
+		case <-w.Done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func toDuration(ns float64) time.Duration {
+	if ns >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return time.Duration(ns)
+}
+
+// WithHeadroomAlerts makes the generator log a warning and raise an EventHeadroom when the fraction
+// of the h28 space left drops to one of thresholds, e.g. 0.25, 0.1 and 0.01.
+func WithHeadroomAlerts(thresholds ...float64) Option {
+	if len(thresholds) == 0 {
+		return invalidOption("WithHeadroomAlerts: thresholds cannot be empty")
+	}
+	for _, v := range thresholds {
+		if v <= 0 || v >= 1 {
+			return invalidOption("WithHeadroomAlerts: threshold must be in between (0, 1)")
+		}
+	}
+	a := append([]float64(nil), thresholds...)
+	sort.Sort(sort.Reverse(sort.Float64Slice(a)))
+	return func(w *WUID) {
+		w.HeadroomThresholds = a
+	}
+}
@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWUID_Forecast(t *testing.T) {
+	w := NewWUID("alpha", nil)
+	if fc := w.Forecast(); fc.H28 != 0 || !fc.H28ExhaustedAt.IsZero() {
+		t.Fatal("Forecast should be empty before h28 is loaded")
+	}
+	if err := w.LoadH28(100, nil); err != nil {
+		t.Fatal(err)
+	}
+	if fc := w.Forecast(); fc.H28 != 100 || fc.H28Limit != 0x07FFFFFF || !fc.H28ExhaustedAt.IsZero() {
+		t.Fatalf("unexpected forecast: %+v", fc)
+	}
+
+	w.forecast.samples[0].at = time.Now().Add(-time.Hour)
+	if err := w.LoadH28(200, nil); err != nil {
+		t.Fatal(err)
+	}
+	fc := w.Forecast()
+	if fc.H28Rate < 99 || fc.H28Rate > 101 {
+		t.Fatalf("the rate should be about 100 per hour. rate: %f", fc.H28Rate)
+	}
+	if d := time.Until(fc.H28ExhaustedAt) - time.Duration((0x07FFFFFF-200)/100)*time.Hour; d > time.Hour || d < -time.Hour {
+		t.Fatalf("unexpected H28ExhaustedAt: %s", fc.H28ExhaustedAt)
+	}
+
+	w.forecast.blockLoadedAt = time.Now().Add(-time.Second)
+	atomic.AddInt64(&w.N, PanicValue/100)
+	fc = w.Forecast()
+	if d := time.Until(fc.BlockExhaustedAt); d < time.Second*90 || d > time.Second*110 {
+		t.Fatalf("the block should run out in about 99 seconds. d: %s", d)
+	}
+}
+
+func TestWithHeadroomAlerts(t *testing.T) {
+	var events []Event
+	w := NewWUID("alpha", nil, WithHeadroomAlerts(0.1, 0.25, 0.01),
+		WithEventHandler(func(e Event) { events = append(events, e) }))
+	for _, h28 := range []int64{0x07FFFFFF / 2, 0x07FFFFFF / 10 * 8, 0x07FFFFFF/10*8 + 1, 0x07FFFFFF / 1000 * 995} {
+		if err := w.LoadH28(h28, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(events) != 2 {
+		t.Fatalf("there should be 2 events. len(events): %d", len(events))
+	}
+	if e := events[0]; e.Kind != EventHeadroom || e.Headroom > 0.25 || e.Headroom < 0.1 {
+		t.Fatalf("unexpected event: %+v", e)
+	}
+	if e := events[1]; e.Headroom > 0.01 {
+		t.Fatalf("unexpected event: %+v", e)
+	}
+
+	for _, thresholds := range [][]float64{nil, {0}, {1.5}} {
+		func() {
+			defer func() { _ = recover() }()
+			NewWUID("alpha", nil, WithHeadroomAlerts(thresholds...))
+			t.Fatal("WithHeadroomAlerts should have panicked")
+		}()
+	}
+}
+
+func TestWUID_SampleH28(t *testing.T) {
+	a := &fakeAdvancer{n: 100}
+	w := NewWUID("alpha", nil)
+	defer w.Close()
+	if err := w.SampleH28(a, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	startTime := time.Now()
+	for time.Since(startTime) < time.Second && w.Forecast().H28 != 100 {
+		time.Sleep(time.Millisecond * 10)
+	}
+	if fc := w.Forecast(); fc.H28 != 100 {
+		t.Fatalf("the shared counter should be sampled. h28: %d", fc.H28)
+	}
+
+	// Other generators consume 100 h28 values in an hour, while w loads none.
+	w.forecast.Lock()
+	for i := range w.forecast.samples {
+		w.forecast.samples[i].at = time.Now().Add(-time.Hour)
+	}
+	w.forecast.Unlock()
+	a.Lock()
+	a.n = 200
+	a.Unlock()
+	if _, err := w.Check(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+	if fc := w.Forecast(); fc.H28Rate < 99 || fc.H28Rate > 101 {
+		t.Fatalf("the rate should be about 100 per hour. rate: %f", fc.H28Rate)
+	}
+
+	// A sample taken after some IDs have been generated does not restart the block.
+	if err := w.LoadH28(300, nil); err != nil {
+		t.Fatal(err)
+	}
+	w.forecast.Lock()
+	w.forecast.blockLoadedAt = time.Now().Add(-time.Second * 10)
+	w.forecast.Unlock()
+	atomic.AddInt64(&w.N, PanicValue/100)
+	a.Lock()
+	a.n = 300
+	a.Unlock()
+	if _, err := w.Check(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Until(w.Forecast().BlockExhaustedAt); d < time.Second*900 || d > time.Second*1100 {
+		t.Fatalf("the block should run out in about 990 seconds. d: %s", d)
+	}
+
+	if err := w.SampleH28(nil, time.Second); err == nil {
+		t.Fatal("SampleH28 should fail when src is nil")
+	}
+	f := H28SourceFunc(func(ctx context.Context) (int64, error) { return 1, nil })
+	if err := w.SampleH28(f, time.Second); err == nil {
+		t.Fatal("SampleH28 should fail when src is not an H28Checker")
+	}
+	if err := w.SampleH28(a, time.Millisecond); err == nil {
+		t.Fatal("SampleH28 should fail when interval is too short")
+	}
+}
@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+const (
+	HaltSectionLeaseLost int32 = 1 << iota
+	HaltNotLoaded
+	HaltPaused
+)
+
+func haltReason(h int32) error {
+	switch {
+	case h&HaltSectionLeaseLost != 0:
+		return errors.New("the section lease has been lost")
+	case h&HaltNotLoaded != 0:
+		return errors.New("h28 has not been loaded yet")
+	case h&HaltPaused != 0:
+		return errors.New("the generation is paused by the control channel")
+	default:
+		return errors.New("the generation is halted")
+	}
+}
+
+func (w *WUID) halt(h int32) {
+	for {
+		v := atomic.LoadInt32(&w.Halted)
+		if atomic.CompareAndSwapInt32(&w.Halted, v, v|h) {
+			return
+		}
+	}
+}
+
+func (w *WUID) unhalt(h int32) {
+	for {
+		v := atomic.LoadInt32(&w.Halted)
+		if atomic.CompareAndSwapInt32(&w.Halted, v, v&^h) {
+			return
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrRollback indicates that the data source handed out an h28 that is not greater than the high-water
+// mark in the journal, which usually means the data source lost its data or was restored from a backup.
+var ErrRollback = errors.New("h28 is not greater than the high-water mark")
+
+// Journal records the accepted h28 of each generator in a local file, so that a rollback of the
+// data source can be detected. The file is append-only, and every record is synced to disk.
+type Journal struct {
+	mu   sync.Mutex
+	f    *os.File
+	hwms map[string]int64
+}
+
+// OpenJournal opens the journal at path, and creates it if it does not exist. A journal can be shared
+// by many generators.
+func OpenJournal(path string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &Journal{f: f, hwms: make(map[string]int64)}
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+		if len(line) == 0 {
+			continue
+		}
+		h28, key, err := parseJournalLine(line)
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("corrupted journal. path: %s, line: %d, reason: %w", path, lineNum, err)
+		}
+		if h28 > j.hwms[key] {
+			j.hwms[key] = h28
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+func parseJournalLine(line string) (int64, string, error) {
+	s1, s2, ok := strings.Cut(line, " ")
+	if !ok {
+		return 0, "", errors.New("missing separator")
+	}
+	h28, err := strconv.ParseInt(s1, 10, 64)
+	if err != nil {
+		return 0, "", err
+	}
+	key, err := strconv.Unquote(s2)
+	if err != nil {
+		return 0, "", err
+	}
+	return h28, key, nil
+}
+
+func journalKey(name string, src H28Source) string {
+	return name + "@" + describeSource(src)
+}
+
+// recordKey is the key under which h28 is recorded. The sources of a FailoverSource or a PartitionedSource
+// count independently, and the residue of h28 tells which one it came from, so each has its own high-water mark.
+func recordKey(name string, src H28Source, h28 int64) string {
+	var n int64
+	switch s := src.(type) {
+	case *FailoverSource:
+		n = int64(len(s.sources))
+	case *PartitionedSource:
+		n = int64(len(s.sources))
+	}
+	key := journalKey(name, src)
+	if n > 1 {
+		key += "#" + strconv.FormatInt(h28%n, 10)
+	}
+	return key
+}
+
+// HighWaterMark returns the greatest h28 recorded for key.
+func (j *Journal) HighWaterMark(key string) int64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.hwms[key]
+}
+
+// Record saves h28 as the new high-water mark of key. It fails with ErrRollback if h28 is not greater
+// than the current one.
+func (j *Journal) Record(key string, h28 int64) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.f == nil {
+		return errors.New("the journal is closed")
+	}
+	if hwm := j.hwms[key]; h28 <= hwm {
+		return fmt.Errorf("%w. key: %s, h28: %d, high-water mark: %d", ErrRollback, key, h28, hwm)
+	}
+	if _, err := fmt.Fprintf(j.f, "%d %s\n", h28, strconv.Quote(key)); err != nil {
+		return err
+	}
+	if err := j.f.Sync(); err != nil {
+		return err
+	}
+	j.hwms[key] = h28
+	return nil
+}
+
+// Close closes the underlying file.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.f == nil {
+		return nil
+	}
+	err := j.f.Close()
+	j.f = nil
+	return err
+}
+
+// WithJournal records every accepted h28 in j, keyed by the name of the generator and the data source.
+// An h28 that is not greater than the recorded high-water mark is rejected with ErrRollback, and an
+// EventRollback is raised. The sources of a FailoverSource or a PartitionedSource have their own marks.
+func WithJournal(j *Journal) Option {
+	if j == nil {
+		return invalidOption("WithJournal: j cannot be nil")
+	}
+	return func(w *WUID) {
+		w.Journal = j
+	}
+}
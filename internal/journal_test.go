@@ -0,0 +1,132 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wuid.journal")
+	j, err := OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Record("alpha@redis:wuid", 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Record("alpha@redis:wuid", 7); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Record("beta @mysql:\n", 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Record("alpha@redis:wuid", 7); !errors.Is(err, ErrRollback) {
+		t.Fatal("Record should fail with ErrRollback")
+	}
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Record("alpha@redis:wuid", 8); err == nil {
+		t.Fatal("Record should fail after Close")
+	}
+
+	j, err = OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if j.HighWaterMark("alpha@redis:wuid") != 7 || j.HighWaterMark("beta @mysql:\n") != 3 {
+		t.Fatal("the high-water marks are not restored")
+	}
+
+	if err := os.WriteFile(path, []byte("bomb\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenJournal(path); err == nil {
+		t.Fatal("OpenJournal should fail when the journal is corrupted")
+	}
+}
+
+func TestWithJournal(t *testing.T) {
+	j, err := OpenJournal(filepath.Join(t.TempDir(), "wuid.journal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	var events []Event
+	h28 := int64(10)
+	src := H28SourceFunc(func(ctx context.Context) (int64, error) {
+		return h28, nil
+	})
+	w := NewWUID("alpha", nil, WithJournal(j), WithEventHandler(func(e Event) {
+		events = append(events, e)
+	}))
+	if err := w.LoadH28FromSource(src); err != nil {
+		t.Fatal(err)
+	}
+
+	h28 = 20
+	if err := w.RenewNow(); err != nil {
+		t.Fatal(err)
+	}
+
+	h28 = 15
+	if err := w.RenewNow(); !errors.Is(err, ErrRollback) {
+		t.Fatal("RenewNow should fail with ErrRollback")
+	}
+	if len(events) != 1 || events[0].Kind != EventRollback || events[0].H28 != 15 || events[0].Name != "alpha" {
+		t.Fatalf("the rollback event is not raised. events: %+v", events)
+	}
+
+	w2 := NewWUID("beta", nil, WithJournal(j))
+	if err := w2.LoadH28FromSource(src); err != nil {
+		t.Fatal("the journal should be keyed by the generator name")
+	}
+}
+
+func TestWithJournal_Failover(t *testing.T) {
+	j, err := OpenJournal(filepath.Join(t.TempDir(), "wuid.journal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	var down bool
+	h1, h2 := int64(100), int64(3)
+	src1 := H28SourceFunc(func(ctx context.Context) (int64, error) {
+		if down {
+			return 0, errors.New("down")
+		}
+		return h1, nil
+	})
+	src2 := H28SourceFunc(func(ctx context.Context) (int64, error) {
+		return h2, nil
+	})
+	fs := NewFailoverSource(nil, src1, src2)
+	w := NewWUID("alpha", nil, WithJournal(j))
+	if err := w.LoadH28FromSource(fs); err != nil {
+		t.Fatal(err)
+	}
+
+	down = true
+	if err := w.RenewNow(); err != nil {
+		t.Fatalf("the secondary source should have its own high-water mark. reason: %+v", err)
+	}
+	if h28 := atomic.LoadInt64(&w.N) >> 36; h28 != 7 {
+		t.Fatalf("h28 should come from the secondary source. h28: %d", h28)
+	}
+
+	down, h1 = false, 101
+	if err := w.RenewNow(); err != nil {
+		t.Fatal(err)
+	}
+	h1 = 50
+	if err := w.RenewNow(); !errors.Is(err, ErrRollback) {
+		t.Fatal("RenewNow should fail with ErrRollback when the primary source rolls back")
+	}
+}
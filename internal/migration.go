@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+)
+
+// IDRange is a closed range of IDs.
+type IDRange struct {
+	Min int64
+	Max int64
+}
+
+// blockRange returns the range of the numbers generated with h28.
+func (w *WUID) blockRange(h28 int64) (lo, hi int64) {
+	lo = h28<<36 | w.Section
+	hi = lo | L36Mask
+	return
+}
+
+func (w *WUID) checkExclusions(h28 int64) error {
+	lo, hi := w.blockRange(h28)
+	if lo < w.MinID {
+		return fmt.Errorf("the numbers generated with h28 %d would not exceed the minimum ID %d", h28, w.MinID)
+	}
+	for _, r := range w.ExcludedRanges {
+		if lo <= r.Max && r.Min <= hi {
+			return fmt.Errorf("the numbers generated with h28 %d would intersect the excluded range [%d, %d]", h28, r.Min, r.Max)
+		}
+	}
+	return nil
+}
+
+// NextValidH28 returns the smallest h28, which is not less than the argument h28, whose numbers exceed
+// the minimum ID and intersect none of the excluded ranges.
+func (w *WUID) NextValidH28(h28 int64) int64 {
+	d := w.MinID - w.Section
+	v := d >> 36
+	if d&L36Mask != 0 {
+		v++
+	}
+	if h28 < v {
+		h28 = v
+	}
+	for {
+		moved := false
+		lo, hi := w.blockRange(h28)
+		for _, r := range w.ExcludedRanges {
+			if lo <= r.Max && r.Min <= hi {
+				h28 = (r.Max-w.Section)>>36 + 1
+				lo, hi = w.blockRange(h28)
+				moved = true
+			}
+		}
+		if !moved {
+			return h28
+		}
+	}
+}
+
+// AdvancePastExclusions acquires a number from src. If the next one would not be valid because of
+// the minimum ID or the excluded ranges, src is advanced so that the next acquisition returns a valid
+// h28. It returns the lowest h28 that src will hand out from now on. It fails without advancing src
+// if that h28 would exceed the limit of h28, which would break all the generators sharing src.
+func (w *WUID) AdvancePastExclusions(ctx context.Context, src H28Advancer) (int64, error) {
+	h28, err := src.Acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	next := w.NextValidH28(h28 + 1)
+	if limit := w.h28Limit(); next > limit {
+		return 0, fmt.Errorf("no valid h28 is left below the limit. next: %d, limit: %d", next, limit)
+	}
+	if next == h28+1 {
+		return next, nil
+	}
+	if err := src.AdvanceTo(ctx, next-1); err != nil {
+		return 0, err
+	}
+	w.Infof("<wuid> the data source is advanced past the exclusions. name: %s, h28: %d", w.Name, next)
+	return next, nil
+}
+
+// WithMinID makes VerifyH28 reject any h28 that could produce a number less than min, e.g. one more than
+// the current maximum of an AUTO_INCREMENT column. It cannot work with WithTimestamp or WithSnowflake.
+func WithMinID(min int64) Option {
+	if min < 0 {
+		return invalidOption("WithMinID: min cannot be negative")
+	}
+	return func(w *WUID) {
+		w.MinID = min
+	}
+}
+
+// WithExcludedRanges makes VerifyH28 reject any h28 whose numbers would intersect one of ranges.
+// It can be used more than once. It cannot work with WithTimestamp or WithSnowflake.
+func WithExcludedRanges(ranges ...IDRange) Option {
+	for _, r := range ranges {
+		if r.Min < 0 || r.Min > r.Max {
+			return invalidOption("WithExcludedRanges: invalid range [%d, %d]", r.Min, r.Max)
+		}
+	}
+	return func(w *WUID) {
+		w.ExcludedRanges = append(w.ExcludedRanges, ranges...)
+	}
+}
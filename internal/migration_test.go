@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithMinID(t *testing.T) {
+	w := NewWUID("alpha", nil, WithMinID(5<<36+100))
+	if w.VerifyH28(5) == nil {
+		t.Fatal(`w.VerifyH28(5) == nil`)
+	}
+	if err := w.VerifyH28(6); err != nil {
+		t.Fatal(err)
+	}
+	if v := w.NextValidH28(1); v != 6 {
+		t.Fatalf("w.NextValidH28(1) returned %d", v)
+	}
+	if v := w.NextValidH28(7); v != 7 {
+		t.Fatalf("w.NextValidH28(7) returned %d", v)
+	}
+
+	w = NewWUID("alpha", nil, WithSection(1), WithMinID(1<<60|3<<36))
+	if w.VerifyH28(2) == nil {
+		t.Fatal(`w.VerifyH28(2) == nil`)
+	}
+	if err := w.VerifyH28(3); err != nil {
+		t.Fatal(err)
+	}
+
+	func() {
+		defer func() { _ = recover() }()
+		NewWUID("alpha", nil, WithMinID(-1))
+		t.Fatal("WithMinID should have panicked")
+	}()
+	func() {
+		defer func() { _ = recover() }()
+		NewWUID("alpha", nil, WithMinID(1), WithTimestamp(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Minute))
+		t.Fatal("WithMinID should have panicked")
+	}()
+}
+
+func TestWithExcludedRanges(t *testing.T) {
+	w := NewWUID("alpha", nil, WithExcludedRanges(IDRange{Min: 3<<36 + 5, Max: 3<<36 + 9}),
+		WithExcludedRanges(IDRange{Min: 4 << 36, Max: 6<<36 - 1}, IDRange{Min: 10 << 36, Max: 10 << 36}))
+	for _, h28 := range []int64{3, 4, 5, 10} {
+		if w.VerifyH28(h28) == nil {
+			t.Fatalf("w.VerifyH28(%d) should have failed", h28)
+		}
+	}
+	for _, h28 := range []int64{2, 6, 9, 11} {
+		if err := w.VerifyH28(h28); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if v := w.NextValidH28(3); v != 6 {
+		t.Fatalf("w.NextValidH28(3) returned %d", v)
+	}
+	if v := w.NextValidH28(10); v != 11 {
+		t.Fatalf("w.NextValidH28(10) returned %d", v)
+	}
+
+	func() {
+		defer func() { _ = recover() }()
+		NewWUID("alpha", nil, WithExcludedRanges(IDRange{Min: 2, Max: 1}))
+		t.Fatal("WithExcludedRanges should have panicked")
+	}()
+}
+
+func TestWUID_AdvancePastExclusions(t *testing.T) {
+	w := NewWUID("alpha", nil, WithMinID(20<<36), WithExcludedRanges(IDRange{Min: 20 << 36, Max: 22 << 36}))
+	src := &fakeAdvancer{}
+	next, err := w.AdvancePastExclusions(context.Background(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != 23 {
+		t.Fatalf("next should be 23. next: %d", next)
+	}
+	if err := w.LoadH28FromSource(src); err != nil {
+		t.Fatal(err)
+	}
+	if v := atomic.LoadInt64(&w.N) >> 36; v != 23 {
+		t.Fatalf("unexpected h28: %d", v)
+	}
+
+	next, err = w.AdvancePastExclusions(context.Background(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != 25 || src.n != 24 {
+		t.Fatalf("the data source should not have been advanced. next: %d, src.n: %d", next, src.n)
+	}
+}
+
+func TestWUID_AdvancePastExclusions_Limit(t *testing.T) {
+	w1 := NewWUID("alpha", nil, WithMinID(math.MaxInt64))
+	if _, err := w1.AdvancePastExclusions(context.Background(), &fakeAdvancer{}); err == nil {
+		t.Fatal("AdvancePastExclusions should fail when no valid h28 is left")
+	}
+
+	w := NewWUID("alpha", nil, WithExcludedRanges(IDRange{Min: 100 << 36, Max: math.MaxInt64}))
+	src := &fakeAdvancer{n: 99}
+	if _, err := w.AdvancePastExclusions(context.Background(), src); err == nil {
+		t.Fatal("AdvancePastExclusions should fail when no valid h28 is left")
+	}
+	if src.n != 100 {
+		t.Fatalf("the data source should not have been advanced. src.n: %d", src.n)
+	}
+}
@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"github.com/edwingeng/slog"
+	"sync/atomic"
+)
+
+// PartitionedSource acquires h28 from whichever of its sources is reachable. The i-th source of n
+// must only hand out numbers congruent to i modulo n, which is verified on each acquisition, so the
+// sources never collide. Unlike FailoverSource, it sticks to the source that succeeded last time.
+type PartitionedSource struct {
+	logger  slog.Logger
+	sources []H28Source
+	last    int64
+}
+
+func NewPartitionedSource(logger slog.Logger, sources ...H28Source) *PartitionedSource {
+	if len(sources) == 0 {
+		panic("sources cannot be empty")
+	}
+	for _, src := range sources {
+		if src == nil {
+			panic("sources cannot contain nil")
+		}
+	}
+	if logger == nil {
+		logger = slog.NewDevelopmentConfig().MustBuild()
+	}
+	return &PartitionedSource{
+		logger:  logger,
+		sources: append([]H28Source(nil), sources...),
+	}
+}
+
+func (ps *PartitionedSource) Acquire(ctx context.Context) (int64, error) {
+	n := int64(len(ps.sources))
+	start := atomic.LoadInt64(&ps.last)
+	var lastErr error
+	for k := int64(0); k < n; k++ {
+		i := (start + k) % n
+		h28, err := acquireWithin(ctx, ps.sources[i], int(n-k))
+		if err == nil && h28%n != i {
+			err = fmt.Errorf("h28 is not in the partition. h28: %d, partition: %d, partitions: %d", h28, i, n)
+		}
+		if err != nil {
+			ps.logger.Warnf("<wuid> partition #%d failed. reason: %+v", i, err)
+			lastErr = err
+			continue
+		}
+		if i != start {
+			atomic.StoreInt64(&ps.last, i)
+			ps.logger.Warnf("<wuid> switched from partition #%d to partition #%d", start, i)
+		}
+		return h28, nil
+	}
+	return 0, fmt.Errorf("all the partitions failed. last error: %w", lastErr)
+}
+
+func (ps *PartitionedSource) String() string {
+	return describeSources("partitioned", ps.sources)
+}
+
+// Partitioner is implemented by the data sources that only hand out numbers congruent to i modulo n.
+// The residue of each h28 loaded from them is verified along with VerifyH28.
+type Partitioner interface {
+	Partition() (n, i int64)
+}
+
+// verifyH28 is VerifyH28 plus the residue check if src is a Partitioner.
+func (w *WUID) verifyH28(h28 int64, src H28Source) error {
+	if err := w.VerifyH28(h28); err != nil {
+		return err
+	}
+	if p, ok := src.(Partitioner); ok {
+		if n, i := p.Partition(); n > 0 && h28%n != i {
+			return fmt.Errorf("h28 is not in the partition. h28: %d, partition: %d, partitions: %d", h28, i, n)
+		}
+	}
+	return nil
+}
+
+// CheckPartition panics if n or i is invalid.
+func CheckPartition(n, i int64) {
+	if n < 1 || n > 1024 {
+		panic("n must be in between [1, 1024]")
+	}
+	if i < 0 || i >= n {
+		panic("i must be in between [0, n)")
+	}
+}
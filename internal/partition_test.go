@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+type fakePartition struct {
+	n, i, c int64
+	down    int32
+}
+
+func (p *fakePartition) Acquire(ctx context.Context) (int64, error) {
+	if atomic.LoadInt32(&p.down) != 0 {
+		return 0, errors.New("down")
+	}
+	p.c += p.n
+	return p.c + p.i, nil
+}
+
+func (p *fakePartition) Partition() (n, i int64) {
+	return p.n, p.i
+}
+
+func TestWUID_LoadH28FromSource_Partition(t *testing.T) {
+	w := NewWUID("alpha", nil)
+	p := &fakePartition{n: 3, i: 1}
+	if err := w.LoadH28FromSource(p); err != nil {
+		t.Fatal(err)
+	}
+
+	// The counter goes out of the partition, e.g. auto_increment_offset is lost.
+	p.c++
+	if err := w.LoadH28FromSource(p); err == nil {
+		t.Fatal("LoadH28FromSource should fail when h28 is not in the partition")
+	}
+}
+
+func TestPartitionedSource(t *testing.T) {
+	p0, p1, p2 := &fakePartition{n: 3, i: 0}, &fakePartition{n: 3, i: 1}, &fakePartition{n: 3, i: 2}
+	ps := NewPartitionedSource(nil, p0, p1, p2)
+	seen := make(map[int64]struct{})
+	acquire := func(expected int64) {
+		t.Helper()
+		h28, err := ps.Acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h28%3 != expected {
+			t.Fatalf("h28 is not from partition #%d. h28: %d", expected, h28)
+		}
+		if _, ok := seen[h28]; ok {
+			t.Fatalf("duplicate h28: %d", h28)
+		}
+		seen[h28] = struct{}{}
+	}
+
+	acquire(0)
+	atomic.StoreInt32(&p0.down, 1)
+	acquire(1)
+	atomic.StoreInt32(&p0.down, 0)
+	acquire(1)
+	atomic.StoreInt32(&p1.down, 1)
+	acquire(2)
+
+	atomic.StoreInt32(&p0.down, 1)
+	atomic.StoreInt32(&p2.down, 1)
+	if _, err := ps.Acquire(context.Background()); err == nil {
+		t.Fatal("Acquire should fail when all the partitions fail")
+	}
+
+	bad := NewPartitionedSource(nil, &fakePartition{n: 2, i: 1}, &fakePartition{n: 2, i: 0})
+	if _, err := bad.Acquire(context.Background()); err == nil {
+		t.Fatal("Acquire should reject h28 out of the partition")
+	}
+}
@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+)
+
+// ProvenanceRecord describes who took an h28 and when. A block may have more than one holder
+// if it is resumed, in which case Start tells them apart.
+type ProvenanceRecord struct {
+	Name     string
+	Hostname string
+	PID      int
+	Version  string
+	Time     time.Time
+	// Start is the value of the low 36 bits from which the holder started.
+	Start int64
+}
+
+// ProvenanceStore keeps the provenance records of h28 values. It should be dedicated to one data source.
+type ProvenanceStore interface {
+	// Record appends rec to the records of h28.
+	Record(ctx context.Context, h28 int64, rec ProvenanceRecord) error
+	// Lookup returns all the records of h28.
+	Lookup(ctx context.Context, h28 int64) ([]ProvenanceRecord, error)
+}
+
+// ProvenanceFuncs is an adapter to allow the use of ordinary functions as a ProvenanceStore.
+type ProvenanceFuncs struct {
+	RecordFunc func(ctx context.Context, h28 int64, rec ProvenanceRecord) error
+	LookupFunc func(ctx context.Context, h28 int64) ([]ProvenanceRecord, error)
+}
+
+// Record calls f.RecordFunc(ctx, h28, rec).
+func (f ProvenanceFuncs) Record(ctx context.Context, h28 int64, rec ProvenanceRecord) error {
+	return f.RecordFunc(ctx, h28, rec)
+}
+
+// Lookup calls f.LookupFunc(ctx, h28).
+func (f ProvenanceFuncs) Lookup(ctx context.Context, h28 int64) ([]ProvenanceRecord, error) {
+	return f.LookupFunc(ctx, h28)
+}
+
+// ErrNoProvenance indicates that there is no provenance record for an ID.
+var ErrNoProvenance = errors.New("no provenance record")
+
+func (w *WUID) recordProvenance(h28, start int64) {
+	rec := ProvenanceRecord{
+		Name:    w.Name,
+		PID:     os.Getpid(),
+		Version: w.Version,
+		Time:    time.Now(),
+		Start:   start,
+	}
+	rec.Hostname, _ = os.Hostname()
+
+	ctx1, cancel1 := context.WithTimeout(context.Background(), w.RenewTimeout)
+	defer cancel1()
+	if err := w.ProvenanceStore.Record(ctx1, h28, rec); err != nil {
+		w.Warnf("<wuid> failed to record the provenance. name: %s, h28: %d, reason: %+v", w.Name, h28, err)
+	}
+}
+
+// Provenance returns the provenance record of the holder of the block that id was generated from.
+// The lookup is bounded by RenewTimeout.
+func (w *WUID) Provenance(id int64) (*ProvenanceRecord, error) {
+	if w.ProvenanceStore == nil {
+		return nil, errors.New("no provenance store. please use WithProvenance")
+	}
+	p := w.Parse(id)
+	ctx1, cancel1 := context.WithTimeout(context.Background(), w.RenewTimeout)
+	defer cancel1()
+	records, err := w.ProvenanceStore.Lookup(ctx1, p.H28)
+	if err != nil {
+		return nil, err
+	}
+
+	var found *ProvenanceRecord
+	for i := range records {
+		if r := &records[i]; r.Start <= p.L36 && (found == nil || r.Start > found.Start) {
+			found = r
+		}
+	}
+	if found == nil {
+		return nil, ErrNoProvenance
+	}
+	return found, nil
+}
+
+// WithProvenance makes the generator record in store who takes each h28. version usually identifies
+// the deploy, e.g. a git commit. It cannot work with WithSnowflake.
+func WithProvenance(store ProvenanceStore, version string) Option {
+	if store == nil {
+		return invalidOption("WithProvenance: store cannot be nil")
+	}
+	return func(w *WUID) {
+		w.ProvenanceStore = store
+		w.Version = version
+	}
+}
@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestWithProvenance(t *testing.T) {
+	m := make(map[int64][]ProvenanceRecord)
+	store := ProvenanceFuncs{
+		RecordFunc: func(ctx context.Context, h28 int64, rec ProvenanceRecord) error {
+			m[h28] = append(m[h28], rec)
+			return nil
+		},
+		LookupFunc: func(ctx context.Context, h28 int64) ([]ProvenanceRecord, error) {
+			return m[h28], nil
+		},
+	}
+
+	w := NewWUID("alpha", nil, WithProvenance(store, "v1.2.3"))
+	if err := w.LoadH28(5, nil); err != nil {
+		t.Fatal(err)
+	}
+	id := w.Next()
+	rec, err := w.Provenance(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Name != "alpha" || rec.PID != os.Getpid() || rec.Version != "v1.2.3" || rec.Time.IsZero() {
+		t.Fatalf("unexpected record: %+v", rec)
+	}
+
+	// The block is resumed by another holder.
+	m[5] = append(m[5], ProvenanceRecord{Name: "beta", Start: 1000})
+	if rec, err := w.Provenance(id); err != nil || rec.Name != "alpha" {
+		t.Fatalf("the record of alpha should be found. rec: %+v, err: %v", rec, err)
+	}
+	if rec, err := w.Provenance(5<<36 | 1001); err != nil || rec.Name != "beta" {
+		t.Fatalf("the record of beta should be found. rec: %+v, err: %v", rec, err)
+	}
+	if _, err := w.Provenance(6 << 36); !errors.Is(err, ErrNoProvenance) {
+		t.Fatalf("ErrNoProvenance is expected. err: %v", err)
+	}
+
+	if _, err := NewWUID("alpha", nil).Provenance(id); err == nil {
+		t.Fatal("Provenance should fail without a store")
+	}
+	func() {
+		defer func() { _ = recover() }()
+		NewWUID("alpha", nil, WithProvenance(nil, ""))
+		t.Fatal("WithProvenance should have panicked")
+	}()
+}
@@ -0,0 +1,177 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"github.com/edwingeng/slog"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// H28Advancer is an H28Source whose counter can be moved forward.
+type H28Advancer interface {
+	H28Source
+	// AdvanceTo makes sure that the counter is not less than h28. It never moves the counter backward.
+	AdvanceTo(ctx context.Context, h28 int64) error
+}
+
+// H28Register is an H28Advancer whose counter can also be read and compared-and-swapped, which is
+// what a QuorumSource needs.
+type H28Register interface {
+	H28Advancer
+	// Load returns the current value of the counter, or 0 if there is none.
+	Load(ctx context.Context) (int64, error)
+	// CompareAndSwap sets the counter to new if its current value is old. A missing counter is 0.
+	CompareAndSwap(ctx context.Context, old, new int64) (swapped bool, err error)
+}
+
+// QuorumSource keeps the same counter in several independent sources. Acquire reads the counters
+// of a majority, takes the maximum plus one, and compares-and-swaps the counters to it. It succeeds
+// only if a majority are swapped, and retries otherwise. Any two majorities share a source, so two
+// concurrent acquisitions never get the same h28, and a rollback of a minority of the sources, e.g.
+// a flushed Redis or a restored MySQL backup, is outvoted. The counters that lag behind catch up on
+// every acquisition.
+type QuorumSource struct {
+	logger  slog.Logger
+	sources []H28Register
+	quorum  int
+}
+
+// QuorumMaxAttempts is how many times a QuorumSource tries before it gives up on the contention.
+const QuorumMaxAttempts = 32
+
+func NewQuorumSource(logger slog.Logger, sources ...H28Register) *QuorumSource {
+	if len(sources) == 0 {
+		panic("sources cannot be empty")
+	}
+	for _, src := range sources {
+		if src == nil {
+			panic("sources cannot contain nil")
+		}
+	}
+	if logger == nil {
+		logger = slog.NewDevelopmentConfig().MustBuild()
+	}
+	return &QuorumSource{
+		logger:  logger,
+		sources: append([]H28Register(nil), sources...),
+		quorum:  len(sources)/2 + 1,
+	}
+}
+
+func (qs *QuorumSource) Acquire(ctx context.Context) (int64, error) {
+	for attempt := 1; ; attempt++ {
+		h28, ok, err := qs.tryAcquire(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return h28, nil
+		}
+		if attempt == QuorumMaxAttempts {
+			return 0, fmt.Errorf("gave up after %d attempts because of the contention", attempt)
+		}
+
+		// Back off exponentially with jitter, so that the contenders are unlikely to collide again.
+		backoff := time.Millisecond << attempt
+		if backoff > time.Millisecond*100 || backoff <= 0 {
+			backoff = time.Millisecond * 100
+		}
+		d := time.Duration(rand.Int63n(int64(backoff)))
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(d):
+		}
+	}
+}
+
+// tryAcquire makes one attempt. ok is false if the attempt lost a race with another acquisition.
+func (qs *QuorumSource) tryAcquire(ctx context.Context) (h28 int64, ok bool, err error) {
+	n := len(qs.sources)
+	values := make([]int64, n)
+	errs := make([]error, n)
+	qs.each(func(i int, src H28Register) {
+		values[i], errs[i] = src.Load(ctx)
+	})
+
+	var maxH28 int64
+	var numRead int
+	var lastErr error
+	for i, err := range errs {
+		if err != nil {
+			qs.logger.Warnf("<wuid> failed to read source #%d. reason: %+v", i, err)
+			lastErr = err
+			continue
+		}
+		numRead++
+		if values[i] > maxH28 {
+			maxH28 = values[i]
+		}
+	}
+	if numRead < qs.quorum {
+		return 0, false, fmt.Errorf("only %d of %d sources were read, which is less than the quorum %d. last error: %w",
+			numRead, n, qs.quorum, lastErr)
+	}
+
+	next := maxH28 + 1
+	swapped := make([]bool, n)
+	conflicts := make([]bool, n)
+	qs.each(func(i int, src H28Register) {
+		if errs[i] != nil {
+			return
+		}
+		if values[i] < maxH28 {
+			qs.logger.Warnf("<wuid> source #%d lags behind. h28: %d, max: %d", i, values[i], maxH28)
+		}
+		ok, err := src.CompareAndSwap(ctx, values[i], next)
+		switch {
+		case err != nil:
+			qs.logger.Warnf("<wuid> failed to swap source #%d. h28: %d, reason: %+v", i, next, err)
+		case ok:
+			swapped[i] = true
+		default:
+			conflicts[i] = true
+		}
+	})
+
+	var numSwapped, numConflicts int
+	for i := range swapped {
+		if swapped[i] {
+			numSwapped++
+		}
+		if conflicts[i] {
+			numConflicts++
+		}
+	}
+	switch {
+	case numSwapped >= qs.quorum:
+		return next, true, nil
+	case numConflicts > 0:
+		return 0, false, nil
+	default:
+		return 0, false, fmt.Errorf("only %d of %d sources reached h28 %d, which is less than the quorum %d",
+			numSwapped, n, next, qs.quorum)
+	}
+}
+
+func (qs *QuorumSource) String() string {
+	a := make([]H28Source, len(qs.sources))
+	for i, src := range qs.sources {
+		a[i] = src
+	}
+	return describeSources("quorum", a)
+}
+
+func (qs *QuorumSource) each(f func(i int, src H28Register)) {
+	var wg sync.WaitGroup
+	for i, src := range qs.sources {
+		wg.Add(1)
+		go func(i int, src H28Register) {
+			defer wg.Done()
+			f(i, src)
+		}(i, src)
+	}
+	wg.Wait()
+}
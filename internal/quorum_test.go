@@ -0,0 +1,145 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeAdvancer struct {
+	sync.Mutex
+	n    int64
+	down bool
+	// jitter makes Load and CompareAndSwap sleep randomly, so that concurrent acquisitions interleave.
+	jitter bool
+}
+
+func (a *fakeAdvancer) Acquire(ctx context.Context) (int64, error) {
+	a.Lock()
+	defer a.Unlock()
+	if a.down {
+		return 0, errors.New("down")
+	}
+	a.n++
+	return a.n, nil
+}
+
+func (a *fakeAdvancer) AdvanceTo(ctx context.Context, h28 int64) error {
+	a.Lock()
+	defer a.Unlock()
+	if a.down {
+		return errors.New("down")
+	}
+	if a.n < h28 {
+		a.n = h28
+	}
+	return nil
+}
+
+func (a *fakeAdvancer) sleep() {
+	if a.jitter {
+		time.Sleep(time.Duration(rand.Int63n(int64(time.Millisecond))))
+	}
+}
+
+func (a *fakeAdvancer) Load(ctx context.Context) (int64, error) {
+	a.sleep()
+	a.Lock()
+	defer a.Unlock()
+	if a.down {
+		return 0, errors.New("down")
+	}
+	return a.n, nil
+}
+
+func (a *fakeAdvancer) CompareAndSwap(ctx context.Context, old, new int64) (bool, error) {
+	a.sleep()
+	a.Lock()
+	defer a.Unlock()
+	if a.down {
+		return false, errors.New("down")
+	}
+	if a.n != old {
+		return false, nil
+	}
+	a.n = new
+	return true, nil
+}
+
+func TestQuorumSource(t *testing.T) {
+	a1, a2, a3 := &fakeAdvancer{}, &fakeAdvancer{}, &fakeAdvancer{}
+	qs := NewQuorumSource(nil, a1, a2, a3)
+	for i := 1; i <= 10; i++ {
+		h28, err := qs.Acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h28 != int64(i) {
+			t.Fatalf("h28 should be %d. h28: %d", i, h28)
+		}
+	}
+
+	// a2 loses its data.
+	a2.n = 0
+	h28, err := qs.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h28 != 11 || a2.n != 11 {
+		t.Fatalf("the lagging source is not advanced. h28: %d, a2.n: %d", h28, a2.n)
+	}
+
+	a3.down = true
+	if h28, err := qs.Acquire(context.Background()); err != nil || h28 != 12 {
+		t.Fatalf("Acquire should succeed with a quorum. h28: %d, err: %v", h28, err)
+	}
+	a2.down = true
+	if _, err := qs.Acquire(context.Background()); err == nil {
+		t.Fatal("Acquire should fail without a quorum")
+	}
+
+	a2.down, a3.down = false, false
+	h28, err = qs.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h28 != 13 || a2.n != h28 || a3.n != h28 {
+		t.Fatalf("the sources are not advanced. h28: %d, a2.n: %d, a3.n: %d", h28, a2.n, a3.n)
+	}
+}
+
+func TestQuorumSource_Concurrency(t *testing.T) {
+	sources := []H28Register{&fakeAdvancer{jitter: true}, &fakeAdvancer{jitter: true}, &fakeAdvancer{jitter: true}}
+	qs := NewQuorumSource(nil, sources...)
+
+	const numWorkers, numAcquisitions = 4, 50
+	var mu sync.Mutex
+	seen := make(map[int64]struct{})
+	var wg sync.WaitGroup
+	for k := 0; k < numWorkers; k++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < numAcquisitions; i++ {
+				h28, err := qs.Acquire(context.Background())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				if _, ok := seen[h28]; ok {
+					t.Errorf("duplicate h28: %d", h28)
+				}
+				seen[h28] = struct{}{}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != numWorkers*numAcquisitions {
+		t.Fatalf("some acquisitions failed. n: %d", len(seen))
+	}
+}
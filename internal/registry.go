@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// Registry keeps the generators created by name.
+type Registry[W any] struct {
+	sync.Mutex
+	New        func(name string) (W, error)
+	NewInBatch func(names []string) ([]W, error)
+	Unwrap     func(w W) *WUID
+	m          map[string]W
+	closed     bool
+}
+
+func (r *Registry[W]) Get(name string) (W, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	var zero W
+	if r.closed {
+		return zero, errors.New("the registry is closed")
+	}
+	if len(name) == 0 {
+		return zero, errors.New("name cannot be empty")
+	}
+	if w, ok := r.m[name]; ok {
+		return w, nil
+	}
+
+	w, err := r.New(name)
+	if err != nil {
+		return zero, err
+	}
+	if r.m == nil {
+		r.m = make(map[string]W)
+	}
+	r.m[name] = w
+	return w, nil
+}
+
+// Preload creates the generators of names that do not exist yet in one go.
+func (r *Registry[W]) Preload(names ...string) error {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.closed {
+		return errors.New("the registry is closed")
+	}
+	var todo []string
+	seen := make(map[string]struct{})
+	for _, name := range names {
+		if len(name) == 0 {
+			return errors.New("name cannot be empty")
+		}
+		if _, ok := r.m[name]; ok {
+			continue
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		todo = append(todo, name)
+	}
+	if len(todo) == 0 {
+		return nil
+	}
+
+	ws, err := r.NewInBatch(todo)
+	if err != nil {
+		return err
+	}
+	if r.m == nil {
+		r.m = make(map[string]W)
+	}
+	for i, name := range todo {
+		r.m[name] = ws[i]
+	}
+	return nil
+}
+
+type RegistryStats struct {
+	NumGenerators    int
+	NumRenewAttempts int64
+	NumRenewed       int64
+}
+
+func (r *Registry[W]) Stats() (stats RegistryStats) {
+	r.Lock()
+	defer r.Unlock()
+	stats.NumGenerators = len(r.m)
+	for _, w := range r.m {
+		x := r.Unwrap(w)
+		stats.NumRenewAttempts += atomic.LoadInt64(&x.Stats.NumRenewAttempts)
+		stats.NumRenewed += atomic.LoadInt64(&x.Stats.NumRenewed)
+	}
+	return
+}
+
+func (r *Registry[W]) Close() {
+	r.Lock()
+	defer r.Unlock()
+	r.closed = true
+	for _, w := range r.m {
+		r.Unwrap(w).Close()
+	}
+}
@@ -0,0 +1,109 @@
+package internal
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	var h28 int64
+	var r Registry[*WUID]
+	r.New = func(name string) (*WUID, error) {
+		if name == "bomb" {
+			return nil, errors.New("bomb")
+		}
+		w := NewWUID(name, nil)
+		w.Reset(atomic.AddInt64(&h28, 1) << 36)
+		return w, nil
+	}
+	r.NewInBatch = func(names []string) ([]*WUID, error) {
+		var ws []*WUID
+		for _, name := range names {
+			w, err := r.New(name)
+			if err != nil {
+				return nil, err
+			}
+			ws = append(ws, w)
+		}
+		return ws, nil
+	}
+	r.Unwrap = func(w *WUID) *WUID {
+		return w
+	}
+
+	w1, err := r.Get("alpha")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w2, err := r.Get("beta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w3, _ := r.Get("alpha"); w3 != w1 || w2 == w1 {
+		t.Fatal("Get does not work as expected")
+	}
+	if _, err := r.Get("bomb"); err == nil {
+		t.Fatal("Get should fail when New fails")
+	}
+	if _, err := r.Get(""); err == nil {
+		t.Fatal("Get should fail when name is empty")
+	}
+
+	atomic.StoreInt64(&w1.Stats.NumRenewAttempts, 3)
+	atomic.StoreInt64(&w2.Stats.NumRenewAttempts, 2)
+	atomic.StoreInt64(&w2.Stats.NumRenewed, 1)
+	if stats := r.Stats(); stats != (RegistryStats{NumGenerators: 2, NumRenewAttempts: 5, NumRenewed: 1}) {
+		t.Fatalf("Stats does not work as expected. stats: %+v", stats)
+	}
+
+	r.Close()
+	for _, w := range []*WUID{w1, w2} {
+		select {
+		case <-w.Done:
+		default:
+			t.Fatal("the generators should be closed")
+		}
+	}
+	if _, err := r.Get("gamma"); err == nil {
+		t.Fatal("Get should fail after Close")
+	}
+}
+
+func TestRegistry_Preload(t *testing.T) {
+	var numCalls int
+	var r Registry[*WUID]
+	r.NewInBatch = func(names []string) ([]*WUID, error) {
+		numCalls++
+		var ws []*WUID
+		for _, name := range names {
+			if name == "bomb" {
+				return nil, errors.New("bomb")
+			}
+			ws = append(ws, NewWUID(name, nil))
+		}
+		return ws, nil
+	}
+	r.Unwrap = func(w *WUID) *WUID {
+		return w
+	}
+
+	if err := r.Preload("alpha", "beta", "alpha"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Preload("alpha", "beta"); err != nil {
+		t.Fatal(err)
+	}
+	if numCalls != 1 || r.Stats().NumGenerators != 2 {
+		t.Fatalf("Preload does not work as expected. numCalls: %d", numCalls)
+	}
+	if err := r.Preload("gamma", "bomb"); err == nil {
+		t.Fatal("Preload should fail when NewInBatch fails")
+	}
+	if err := r.Preload(""); err == nil {
+		t.Fatal("Preload should fail when a name is empty")
+	}
+	if r.Stats().NumGenerators != 2 {
+		t.Fatal(`r.Stats().NumGenerators != 2`)
+	}
+}
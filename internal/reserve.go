@@ -0,0 +1,154 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// reserveMu guards all the reserve files of the process.
+var reserveMu sync.Mutex
+
+// Reserve keeps a few h28 values acquired ahead of time in a local file, which are used when the
+// data source fails. A value is removed from the file before it is used, so it is used at most once
+// even across process restarts. The file must not be shared by processes running at the same time.
+type Reserve struct {
+	Path string
+	Size int
+	TTL  time.Duration
+}
+
+type reserveEntry struct {
+	H28       int64     `json:"h28"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type reserveFile struct {
+	Entries map[string][]reserveEntry `json:"entries"`
+}
+
+func (r *Reserve) load() (*reserveFile, error) {
+	f := &reserveFile{}
+	data, err := os.ReadFile(r.Path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, f); err != nil {
+			return nil, err
+		}
+	}
+	if f.Entries == nil {
+		f.Entries = make(map[string][]reserveEntry)
+	}
+
+	now := time.Now()
+	for key, a := range f.Entries {
+		var alive []reserveEntry
+		for _, e := range a {
+			if e.ExpiresAt.After(now) {
+				alive = append(alive, e)
+			}
+		}
+		if len(alive) > 0 {
+			f.Entries[key] = alive
+		} else {
+			delete(f.Entries, key)
+		}
+	}
+	return f, nil
+}
+
+func (r *Reserve) save(f *reserveFile) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	tmp := r.Path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.Path)
+}
+
+// Len returns the number of unexpired values reserved for key.
+func (r *Reserve) Len(key string) (int, error) {
+	reserveMu.Lock()
+	defer reserveMu.Unlock()
+	f, err := r.load()
+	if err != nil {
+		return 0, err
+	}
+	return len(f.Entries[key]), nil
+}
+
+// Put reserves h28 for key.
+func (r *Reserve) Put(key string, h28 int64) error {
+	reserveMu.Lock()
+	defer reserveMu.Unlock()
+	f, err := r.load()
+	if err != nil {
+		return err
+	}
+	f.Entries[key] = append(f.Entries[key], reserveEntry{H28: h28, ExpiresAt: time.Now().Add(r.TTL)})
+	return r.save(f)
+}
+
+// Take removes the oldest unexpired value reserved for key from the file and returns it.
+func (r *Reserve) Take(key string) (int64, bool, error) {
+	reserveMu.Lock()
+	defer reserveMu.Unlock()
+	f, err := r.load()
+	if err != nil {
+		return 0, false, err
+	}
+	a := f.Entries[key]
+	if len(a) == 0 {
+		return 0, false, nil
+	}
+	h28 := a[0].H28
+	if len(a) > 1 {
+		f.Entries[key] = a[1:]
+	} else {
+		delete(f.Entries, key)
+	}
+	if err := r.save(f); err != nil {
+		return 0, false, err
+	}
+	return h28, true, nil
+}
+
+// WithReserve keeps size h28 values acquired ahead of time from the data source in the file at path. When
+// the data source fails, loading and renewal fall back to the reserved values, each of which is removed from
+// the file before it is used, so it is used at most once even across process restarts. A reserved value
+// expires after ttl. The file must not be shared by processes running at the same time. It cannot work
+// with WithSnowflake.
+func WithReserve(path string, size int, ttl time.Duration) Option {
+	if len(path) == 0 {
+		return invalidOption("WithReserve: path cannot be empty")
+	}
+	if size < 1 || size > 100 {
+		return invalidOption("WithReserve: size must be in between [1, 100]")
+	}
+	if ttl <= 0 {
+		return invalidOption("WithReserve: ttl must be positive")
+	}
+	return func(w *WUID) {
+		w.Reserve = &Reserve{Path: path, Size: size, TTL: ttl}
+	}
+}
@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithReserve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wuid.reserve")
+	var h28 int64
+	var down int32
+	src := H28SourceFunc(func(ctx context.Context) (int64, error) {
+		if atomic.LoadInt32(&down) != 0 {
+			return 0, errors.New("down")
+		}
+		return atomic.AddInt64(&h28, 1), nil
+	})
+
+	w1 := NewWUID("alpha", nil, WithReserve(path, 2, time.Hour))
+	if err := w1.LoadH28FromSource(src); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := w1.Reserve.Len(journalKey("alpha", src)); err != nil || n != 2 {
+		t.Fatalf("the reserve is not filled. n: %d, err: %v", n, err)
+	}
+
+	// The data source fails when a new process starts.
+	atomic.StoreInt32(&down, 1)
+	w2 := NewWUID("alpha", nil, WithReserve(path, 2, time.Hour))
+	if err := w2.LoadH28FromSource(src); err != nil {
+		t.Fatal(err)
+	}
+	if v := atomic.LoadInt64(&w2.N) >> 36; v != 2 {
+		t.Fatalf("the reserved h28 is not used. h28: %d", v)
+	}
+	if err := w2.RenewNow(); err != nil {
+		t.Fatal(err)
+	}
+	if v := atomic.LoadInt64(&w2.N) >> 36; v != 3 {
+		t.Fatalf("the reserved h28 is not used. h28: %d", v)
+	}
+	if err := w2.RenewNow(); err == nil {
+		t.Fatal("RenewNow should fail when the reserve is used up")
+	}
+
+	// Each reserved value is used at most once.
+	w3 := NewWUID("alpha", nil, WithReserve(path, 2, time.Hour))
+	if err := w3.LoadH28FromSource(src); err == nil {
+		t.Fatal("LoadH28FromSource should fail when the reserve is used up")
+	}
+
+	atomic.StoreInt32(&down, 0)
+	if err := w3.LoadH28FromSource(src); err != nil {
+		t.Fatal(err)
+	}
+	if v := atomic.LoadInt64(&w3.N) >> 36; v != 4 {
+		t.Fatalf("h28 should be 4. h28: %d", v)
+	}
+}
+
+func TestWithReserve_Expired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wuid.reserve")
+	var h28 int64
+	ok := H28SourceFunc(func(ctx context.Context) (int64, error) {
+		return atomic.AddInt64(&h28, 1), nil
+	})
+	bad := H28SourceFunc(func(ctx context.Context) (int64, error) {
+		return 0, errors.New("down")
+	})
+
+	r := &Reserve{Path: path, Size: 1, TTL: time.Millisecond}
+	if err := r.Put(journalKey("alpha", ok), 100); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 10)
+
+	w := NewWUID("alpha", nil, WithReserve(path, 1, time.Millisecond))
+	if err := w.LoadH28FromSource(bad); err == nil {
+		t.Fatal("expired values should not be used")
+	}
+
+	func() {
+		defer func() {
+			_ = recover()
+		}()
+		NewWUID("alpha", nil, WithSnowflake(time.Now(), time.Minute), WithReserve(path, 1, time.Hour))
+		t.Fatal("WithSnowflake and WithReserve should not be used together")
+	}()
+}
+
+func TestWUID_LoadAcquiredH28(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wuid.reserve")
+	var h28 int64
+	src := H28SourceFunc(func(ctx context.Context) (int64, error) {
+		return atomic.AddInt64(&h28, 1), nil
+	})
+
+	w1 := NewWUID("alpha", nil, WithReserve(path, 2, time.Hour))
+	if err := w1.LoadAcquiredH28(context.Background(), src, atomic.AddInt64(&h28, 1), nil); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := w1.Reserve.Len(journalKey("alpha", src)); err != nil || n != 2 {
+		t.Fatalf("the reserve is not filled. n: %d, err: %v", n, err)
+	}
+
+	w2 := NewWUID("alpha", nil, WithReserve(path, 2, time.Hour))
+	if err := w2.LoadAcquiredH28(context.Background(), src, 0, errors.New("down")); err != nil {
+		t.Fatal(err)
+	}
+	if v := atomic.LoadInt64(&w2.N) >> 36; v != 2 {
+		t.Fatalf("the reserved h28 is not used. h28: %d", v)
+	}
+}
@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// SectionLeaser is implemented by the data sources that are able to lease sections.
+type SectionLeaser interface {
+	// LeaseSection claims or extends the lease of section on behalf of owner. It also records
+	// h28 as the highest h28 used in section. ok is false if section is held by another owner,
+	// or if claim is false and section is not held by owner. Otherwise, maxH28 is the highest h28
+	// recorded in section before the call. ctx is always bounded below ttl.
+	LeaseSection(ctx context.Context, section int64, owner string, ttl time.Duration, h28 int64, claim bool) (ok bool, maxH28 int64, err error)
+}
+
+type sectionLease struct {
+	leaser  SectionLeaser
+	section int64
+	owner   string
+	ttl     time.Duration
+}
+
+// LeaseSection joins the section that owner already holds in leaser, or claims a free one, and keeps
+// renewing the lease in the background until w is closed. The generators of one owner share a section. Every h28 is recorded in the lease before it is used, and the load fails if
+// that cannot be done. Next panics if the lease is lost.
+func (w *WUID) LeaseSection(leaser SectionLeaser, owner string, ttl time.Duration) error {
+	if leaser == nil {
+		return errors.New("leaser cannot be nil")
+	}
+	if len(owner) == 0 {
+		return errors.New("owner cannot be empty")
+	}
+	if ttl < time.Second {
+		return errors.New("ttl cannot be less than a second")
+	}
+	if w.Flags&12 != 0 {
+		return errors.New("the section cannot work with the timestamp mode or the snowflake mode")
+	}
+	if atomic.LoadInt64(&w.N) != 0 {
+		return errors.New("the section must be leased before h28 is loaded")
+	}
+	if w.section != nil {
+		return errors.New("the section is already leased")
+	}
+
+	bits := w.SectionBits
+	if bits == 0 {
+		bits = 3
+	}
+	total := int64(1) << bits
+	start := rand.Int63n(total)
+	for i := int64(0); i < total*2; i++ {
+		// The first round only looks for a section held by owner. The second one claims a free section.
+		section, claim := (start+i)%total, i >= total
+		ctx1, cancel1 := context.WithTimeout(context.Background(), w.sectionTimeout(ttl))
+		ok, maxH28, err := leaser.LeaseSection(ctx1, section, owner, ttl, 0, claim)
+		cancel1()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		w.Monolithic = false
+		w.SectionBits = bits
+		w.Section = section << (63 - bits)
+		atomic.StoreInt64(&w.SectionH28Floor, maxH28)
+		w.section = &sectionLease{leaser: leaser, section: section, owner: owner, ttl: ttl}
+		w.Infof("<wuid> section leased: %d. name: %s, owner: %s", section, w.Name, owner)
+		go w.keepSectionLease()
+		return nil
+	}
+	return fmt.Errorf("no free section. owner: %s", owner)
+}
+
+// sectionTimeout bounds each round trip of a section lease by the renewal interval, so that a hanging
+// backend cannot keep w from halting after the lease expires.
+func (w *WUID) sectionTimeout(ttl time.Duration) time.Duration {
+	if d := ttl / 4; d < w.RenewTimeout {
+		return d
+	}
+	return w.RenewTimeout
+}
+
+// recordSectionH28 records h28 in the section lease, so that the next holder of the section never
+// uses it again even if w crashes right after.
+func (w *WUID) recordSectionH28(h28 int64) error {
+	s := w.section
+	ctx, cancel := context.WithTimeout(context.Background(), w.sectionTimeout(s.ttl))
+	defer cancel()
+	ok, _, err := s.leaser.LeaseSection(ctx, s.section, s.owner, s.ttl, h28, true)
+	if err != nil {
+		return fmt.Errorf("failed to record h28 in the section lease. section: %d, h28: %d, reason: %w", s.section, h28, err)
+	}
+	if !ok {
+		w.halt(HaltSectionLeaseLost)
+		return fmt.Errorf("the section lease was taken by another owner. section: %d", s.section)
+	}
+	return nil
+}
+
+func (w *WUID) keepSectionLease() {
+	s := w.section
+	leaser, section, owner, ttl := s.leaser, s.section, s.owner, s.ttl
+	interval := ttl / 4
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastOK := time.Now()
+	for {
+		select {
+		case <-w.Done:
+			return
+		case <-ticker.C:
+		}
+
+		start := time.Now()
+		h28 := w.Parse(atomic.LoadInt64(&w.N)).H28
+		ctx1, cancel1 := context.WithTimeout(context.Background(), w.sectionTimeout(ttl))
+		ok, _, err := leaser.LeaseSection(ctx1, section, owner, ttl, h28, true)
+		cancel1()
+		switch {
+		case err != nil:
+			w.Warnf("<wuid> failed to renew the section lease. name: %s, section: %d, reason: %+v", w.Name, section, err)
+			if time.Since(lastOK)+interval >= ttl {
+				w.halt(HaltSectionLeaseLost)
+			}
+		case !ok:
+			w.Warnf("<wuid> the section lease was taken by another owner. name: %s, section: %d", w.Name, section)
+			w.halt(HaltSectionLeaseLost)
+			return
+		default:
+			lastOK = start
+			w.unhalt(HaltSectionLeaseLost)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeLeaser struct {
+	sync.Mutex
+	owners map[int64]string
+	h28s   map[int64]int64
+	err    error
+	// hang makes LeaseSection block until ctx is done, like a blackholed backend.
+	hang bool
+}
+
+func newFakeLeaser() *fakeLeaser {
+	return &fakeLeaser{owners: make(map[int64]string), h28s: make(map[int64]int64)}
+}
+
+func (l *fakeLeaser) LeaseSection(ctx context.Context, section int64, owner string, ttl time.Duration, h28 int64, claim bool) (bool, int64, error) {
+	l.Lock()
+	defer l.Unlock()
+	if l.hang {
+		l.Unlock()
+		<-ctx.Done()
+		l.Lock()
+		return false, 0, ctx.Err()
+	}
+	if l.err != nil {
+		return false, 0, l.err
+	}
+	if x, ok := l.owners[section]; ok && x != owner || !ok && !claim {
+		return false, 0, nil
+	}
+	l.owners[section] = owner
+	maxH28 := l.h28s[section]
+	if h28 > maxH28 {
+		l.h28s[section] = h28
+	}
+	return true, maxH28, nil
+}
+
+func (l *fakeLeaser) set(f func()) {
+	l.Lock()
+	defer l.Unlock()
+	f()
+}
+
+func TestWUID_LeaseSection(t *testing.T) {
+	leaser := newFakeLeaser()
+	for i := int64(0); i < 256; i++ {
+		if i != 200 {
+			leaser.owners[i] = "beta"
+		}
+	}
+	leaser.h28s[200] = 10
+
+	w := NewWUID("alpha", nil, WithSectionBits(8, 0))
+	defer w.Close()
+	if err := w.LeaseSection(leaser, "alpha", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.VerifyH28(10); err == nil {
+		t.Fatal("VerifyH28 should reject the h28 values used in the section before")
+	}
+	if err := w.VerifyH28(11); err != nil {
+		t.Fatal(err)
+	}
+
+	w.Reset(11 << 36)
+	if p := w.Parse(w.Next()); p.Section != 200 || p.H28 != 11 {
+		t.Fatalf("Parse does not work as expected. section: %d, h28: %d", p.Section, p.H28)
+	}
+
+	startTime := time.Now()
+	for time.Since(startTime) < time.Second {
+		var h28 int64
+		leaser.set(func() {
+			h28 = leaser.h28s[200]
+		})
+		if h28 == 11 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if time.Since(startTime) >= time.Second {
+		t.Fatal("the h28 in use is not recorded")
+	}
+
+	w2 := NewWUID("beta", nil, WithSectionBits(8, 0))
+	if err := w2.LeaseSection(leaser, "gamma", time.Second); err == nil {
+		t.Fatal("LeaseSection should fail when there is no free section")
+	}
+
+	leaser.set(func() {
+		leaser.owners[200] = "beta"
+	})
+	startTime = time.Now()
+	for time.Since(startTime) < time.Second && atomic.LoadInt32(&w.Halted) == 0 {
+		time.Sleep(time.Millisecond * 10)
+	}
+	func() {
+		defer func() {
+			_ = recover()
+		}()
+		w.Next()
+		t.Fatal("Next should have panicked after the section lease was lost")
+	}()
+}
+
+func TestWUID_LeaseSection_Error(t *testing.T) {
+	leaser := newFakeLeaser()
+	w := NewWUID("alpha", nil)
+	defer w.Close()
+	if err := w.LeaseSection(nil, "alpha", time.Second); err == nil {
+		t.Fatal("LeaseSection should fail when leaser is nil")
+	}
+	if err := w.LeaseSection(leaser, "", time.Second); err == nil {
+		t.Fatal("LeaseSection should fail when owner is empty")
+	}
+	if err := w.LeaseSection(leaser, "alpha", time.Millisecond); err == nil {
+		t.Fatal("LeaseSection should fail when ttl is too short")
+	}
+	if err := w.LeaseSection(leaser, "alpha", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if w.SectionBits != 3 {
+		t.Fatal(`w.SectionBits != 3`)
+	}
+
+	w.Reset(1 << 36)
+	leaser.set(func() {
+		leaser.err = errors.New("foo")
+	})
+	startTime := time.Now()
+	for time.Since(startTime) < time.Second*2 && atomic.LoadInt32(&w.Halted) == 0 {
+		time.Sleep(time.Millisecond * 10)
+	}
+	if atomic.LoadInt32(&w.Halted) == 0 {
+		t.Fatal("w should be halted when the section lease cannot be renewed")
+	}
+
+	leaser.set(func() {
+		leaser.err = nil
+	})
+	startTime = time.Now()
+	for time.Since(startTime) < time.Second && atomic.LoadInt32(&w.Halted) != 0 {
+		time.Sleep(time.Millisecond * 10)
+	}
+	if atomic.LoadInt32(&w.Halted) != 0 {
+		t.Fatal("w should resume after the section lease is renewed")
+	}
+
+	w3 := NewWUID("alpha", nil)
+	w3.Reset(1 << 36)
+	if err := w3.LeaseSection(leaser, "alpha", time.Second); err == nil {
+		t.Fatal("LeaseSection should fail after h28 is loaded")
+	}
+}
+
+func TestWUID_LeaseSection_SameOwner(t *testing.T) {
+	leaser := newFakeLeaser()
+	beta := NewWUID("beta", nil, WithSectionBits(8, 0))
+	defer beta.Close()
+	if err := beta.LeaseSection(leaser, "beta", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	var section int64 = -1
+	for i := 0; i < 10; i++ {
+		w := NewWUID("alpha", nil, WithSectionBits(8, 0))
+		defer w.Close()
+		if err := w.LeaseSection(leaser, "alpha", time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case w.Section == beta.Section:
+			t.Fatal("the generators of different owners should not share a section")
+		case section < 0:
+			section = w.Section
+		case w.Section != section:
+			t.Fatalf("the generators of one owner should share a section. section: %d, expected: %d", w.Section, section)
+		}
+	}
+	leaser.set(func() {
+		if len(leaser.owners) != 2 {
+			t.Fatalf("only 2 sections should be leased. len(leaser.owners): %d", len(leaser.owners))
+		}
+	})
+}
+
+func TestWUID_LeaseSection_Hang(t *testing.T) {
+	leaser := newFakeLeaser()
+	w := NewWUID("alpha", nil)
+	defer w.Close()
+	if err := w.LeaseSection(leaser, "alpha", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	w.Reset(1 << 36)
+
+	leaser.set(func() {
+		leaser.hang = true
+	})
+	startTime := time.Now()
+	for time.Since(startTime) < time.Second*2 && atomic.LoadInt32(&w.Halted) == 0 {
+		time.Sleep(time.Millisecond * 10)
+	}
+	if atomic.LoadInt32(&w.Halted) == 0 {
+		t.Fatal("w should be halted when the backend hangs")
+	}
+}
+
+func TestWUID_LeaseSection_Load(t *testing.T) {
+	leaser := newFakeLeaser()
+	w := NewWUID("alpha", nil)
+	defer w.Close()
+	if err := w.LeaseSection(leaser, "alpha", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.LeaseSection(leaser, "alpha", time.Minute); err == nil {
+		t.Fatal("LeaseSection should fail when the section is already leased")
+	}
+	section := w.Section >> 60
+
+	var h28 int64 = 5
+	src := H28SourceFunc(func(ctx context.Context) (int64, error) { return h28, nil })
+	if err := w.LoadH28FromSource(src); err != nil {
+		t.Fatal(err)
+	}
+	if leaser.h28s[section] != 5 {
+		t.Fatalf("h28 should be recorded before it is used. recorded: %d", leaser.h28s[section])
+	}
+
+	h28 = 6
+	leaser.set(func() {
+		leaser.err = errors.New("foo")
+	})
+	if err := w.LoadH28FromSource(src); err == nil {
+		t.Fatal("LoadH28FromSource should fail when h28 cannot be recorded")
+	}
+	if w.Parse(atomic.LoadInt64(&w.N)).H28 != 5 {
+		t.Fatal("h28 should not be used when it cannot be recorded")
+	}
+
+	leaser.set(func() {
+		leaser.err = nil
+		leaser.owners[section] = "beta"
+	})
+	if err := w.LoadH28FromSource(src); err == nil {
+		t.Fatal("LoadH28FromSource should fail when the section lease is lost")
+	}
+	if atomic.LoadInt32(&w.Halted) == 0 {
+		t.Fatal("w should be halted when the section lease is lost")
+	}
+}
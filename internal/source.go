@@ -0,0 +1,199 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// H28Source hands out the high 28 bits. Acquire should never return the same value twice
+// for the same generator.
+type H28Source interface {
+	Acquire(ctx context.Context) (h28 int64, err error)
+}
+
+// H28SourceFunc is an adapter to allow the use of an ordinary function as an H28Source.
+type H28SourceFunc func(ctx context.Context) (h28 int64, err error)
+
+// Acquire calls f(ctx).
+func (f H28SourceFunc) Acquire(ctx context.Context) (int64, error) {
+	return f(ctx)
+}
+
+// describeSource returns the String of src if it implements fmt.Stringer, or its type otherwise.
+func describeSource(src H28Source) string {
+	if s, ok := src.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", src)
+}
+
+func describeSources(kind string, sources []H28Source) string {
+	a := make([]string, len(sources))
+	for i, src := range sources {
+		a[i] = describeSource(src)
+	}
+	return kind + "(" + strings.Join(a, ",") + ")"
+}
+
+type cleanUpsKey struct{}
+
+type cleanUps struct {
+	sync.Mutex
+	a    []func()
+	done bool
+}
+
+func (c *cleanUps) run() {
+	c.Lock()
+	a := c.a
+	c.a, c.done = nil, true
+	c.Unlock()
+	for _, f := range a {
+		f()
+	}
+}
+
+// DeferCleanUp makes f run after the load that ctx belongs to has finished, i.e. after the h28
+// acquired is verified and applied, or rejected. f runs at once if ctx does not belong to a load.
+func DeferCleanUp(ctx context.Context, f func()) {
+	if c, ok := ctx.Value(cleanUpsKey{}).(*cleanUps); ok {
+		c.Lock()
+		if !c.done {
+			c.a = append(c.a, f)
+			c.Unlock()
+			return
+		}
+		c.Unlock()
+	}
+	f()
+}
+
+// LoadH28FromSource acquires a number from src and uses it as the high 28 bits. In addition,
+// src is saved for future renewal. The acquisition is bounded by RenewTimeout.
+func (w *WUID) LoadH28FromSource(src H28Source) error {
+	ctx1, cancel1 := context.WithTimeout(context.Background(), w.RenewTimeout)
+	defer cancel1()
+	return w.LoadH28FromSourceContext(ctx1, src)
+}
+
+// LoadH28FromSourceContext is like LoadH28FromSource, but the acquisition is bounded by ctx instead.
+// Future renewals are still bounded by RenewTimeout.
+func (w *WUID) LoadH28FromSourceContext(ctx context.Context, src H28Source) error {
+	if src == nil {
+		return errors.New("src cannot be nil")
+	}
+	return w.loadFromSource(ctx, src, src.Acquire)
+}
+
+// LoadAcquiredH28 is like LoadH28FromSourceContext, but h28 and err are the result of an acquisition
+// from src that has been made already, e.g. in a batch. If a block is resumed, h28 is left unused.
+func (w *WUID) LoadAcquiredH28(ctx context.Context, src H28Source, h28 int64, err error) error {
+	if src == nil {
+		return errors.New("src cannot be nil")
+	}
+	return w.loadFromSource(ctx, src, func(context.Context) (int64, error) {
+		return h28, err
+	})
+}
+
+func (w *WUID) loadFromSource(ctx context.Context, src H28Source, acquire func(ctx context.Context) (int64, error)) error {
+	if w.Flags&8 != 0 {
+		return errSnowflakeH28
+	}
+
+	c := &cleanUps{}
+	defer c.run()
+	ctx = context.WithValue(ctx, cleanUpsKey{}, c)
+
+	if w.blocks != nil {
+		h28, err := w.claimBlock(ctx)
+		if err != nil {
+			w.Warnf("<wuid> failed to claim a block. name: %s, reason: %+v", w.Name, err)
+		}
+		if h28 != 0 {
+			// The block was recorded in the journal when it was first loaded.
+			err = w.loadH28(h28, src, true)
+			if err == nil {
+				return nil
+			}
+			w.Warnf("<wuid> failed to resume the block. name: %s, h28: %d, reason: %+v", w.Name, h28, err)
+		}
+	}
+
+	h28, err := acquire(ctx)
+	if err != nil {
+		if w.Reserve == nil {
+			return err
+		}
+		h, ok, err2 := w.Reserve.Take(journalKey(w.Name, src))
+		if err2 != nil {
+			w.Warnf("<wuid> failed to read the reserve. name: %s, reason: %+v", w.Name, err2)
+		}
+		if !ok {
+			return err
+		}
+		w.Warnf("<wuid> the data source failed, so a reserved h28 is used. name: %s, h28: %d, reason: %+v", w.Name, h, err)
+		return w.loadH28(h, src, true)
+	}
+	if err := w.LoadH28(h28, src); err != nil {
+		return err
+	}
+	if w.Reserve != nil {
+		w.refillReserve(ctx, src)
+	}
+	return nil
+}
+
+// DefaultRenewTimeout is the default value of RenewTimeout.
+const DefaultRenewTimeout = time.Second * 5
+
+// MaxRenewTimeout returns the longest RenewTimeout of ws, or DefaultRenewTimeout if ws is empty.
+// unwrap returns the generator inside each element of ws.
+func MaxRenewTimeout[W any](ws []W, unwrap func(W) *WUID) time.Duration {
+	if len(ws) == 0 {
+		return DefaultRenewTimeout
+	}
+	var d time.Duration
+	for _, w := range ws {
+		if x := unwrap(w).RenewTimeout; x > d {
+			d = x
+		}
+	}
+	return d
+}
+
+// WithRenewTimeout sets the timeout of each renewal, which defaults to 5 seconds. It also bounds
+// the Load functions that do not take a context.
+func WithRenewTimeout(timeout time.Duration) Option {
+	if timeout <= 0 {
+		return invalidOption("WithRenewTimeout: timeout must be positive")
+	}
+	return func(w *WUID) {
+		w.RenewTimeout = timeout
+	}
+}
+
+func (w *WUID) refillReserve(ctx context.Context, src H28Source) {
+	key := journalKey(w.Name, src)
+	n, err := w.Reserve.Len(key)
+	for ; err == nil && n < w.Reserve.Size; n++ {
+		var h28 int64
+		h28, err = src.Acquire(ctx)
+		if err == nil {
+			err = w.verifyH28(h28, src)
+		}
+		if err == nil && w.Journal != nil {
+			err = w.Journal.Record(recordKey(w.Name, src, h28), h28)
+		}
+		if err == nil {
+			err = w.Reserve.Put(key, h28)
+		}
+	}
+	if err != nil {
+		w.Warnf("<wuid> failed to refill the reserve. name: %s, reason: %+v", w.Name, err)
+	}
+}
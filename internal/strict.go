@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/edwingeng/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// claims maps the name and the data source of each strict generator to the generator, so that two
+// strict generators in one process never share them.
+var claims struct {
+	sync.Mutex
+	m map[string]*WUID
+}
+
+// New creates a new WUID instance in the strict mode, in which Next panics and TryNext fails until
+// h28 is loaded for the first time. Besides, loading fails if another strict generator in the process
+// has the same name and data source. Use WithStrict(false) to opt out. Unlike NewWUID, it returns an
+// *OptionError that describes all the invalid options instead of panicking.
+func New(name string, logger slog.Logger, opts ...Option) (*WUID, error) {
+	if len(name) == 0 {
+		return nil, errors.New("name cannot be empty")
+	}
+	return newWUID(name, logger, append([]Option{WithStrict(true)}, opts...)...)
+}
+
+func (w *WUID) claim(key string) error {
+	claims.Lock()
+	defer claims.Unlock()
+	if owner, ok := claims.m[key]; ok {
+		if owner == w {
+			return nil
+		}
+		return fmt.Errorf("another generator with the same name and data source exists in the process. key: %s", key)
+	}
+	if claims.m == nil {
+		claims.m = make(map[string]*WUID)
+	}
+	claims.m[key] = w
+	return nil
+}
+
+func (w *WUID) releaseClaims() {
+	claims.Lock()
+	defer claims.Unlock()
+	for key, owner := range claims.m {
+		if owner == w {
+			delete(claims.m, key)
+		}
+	}
+}
+
+func (w *WUID) markLoaded() {
+	w.loadedOnce.Do(func() {
+		w.unhalt(HaltNotLoaded)
+		close(w.Loaded)
+	})
+}
+
+// TryNext is like Next, but returns an error instead of panicking.
+func (w *WUID) TryNext() (id int64, err error) {
+	if h := atomic.LoadInt32(&w.Halted); h != 0 {
+		return 0, haltReason(h)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return w.Next(), nil
+}
+
+// WaitLoaded blocks until h28 is loaded for the first time, ctx is done or w is closed.
+func (w *WUID) WaitLoaded(ctx context.Context) error {
+	select {
+	case <-w.Loaded:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-w.Done:
+		return errors.New("the generator is closed")
+	}
+}
+
+// WithStrict enables or disables the strict mode. See New for details.
+func WithStrict(strict bool) Option {
+	return func(w *WUID) {
+		w.Strict = strict
+	}
+}
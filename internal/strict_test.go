@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNew_Strict(t *testing.T) {
+	if _, err := New("", nil); err == nil {
+		t.Fatal("New should fail when name is empty")
+	}
+
+	w, err := New("strict-alpha", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if _, err := w.TryNext(); err == nil {
+		t.Fatal("TryNext should fail before h28 is loaded")
+	}
+	func() {
+		defer func() {
+			_ = recover()
+		}()
+		w.Next()
+		t.Fatal("Next should have panicked")
+	}()
+
+	var h28 int64
+	src := H28SourceFunc(func(ctx context.Context) (int64, error) {
+		return atomic.AddInt64(&h28, 1), nil
+	})
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		_ = w.LoadH28FromSource(src)
+	}()
+	ctx1, cancel1 := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel1()
+	if err := w.WaitLoaded(ctx1); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := w.TryNext(); err != nil || id != 1<<36+1 {
+		t.Fatalf("TryNext does not work as expected. id: %d, err: %v", id, err)
+	}
+
+	w2, _ := New("strict-alpha", nil)
+	defer w2.Close()
+	if err := w2.LoadH28FromSource(src); err == nil {
+		t.Fatal("two strict generators should not share the same name and data source")
+	}
+	w.Close()
+	if err := w2.LoadH28FromSource(src); err != nil {
+		t.Fatal(err)
+	}
+
+	w3 := NewWUID("strict-alpha", nil)
+	if err := w3.LoadH28FromSource(src); err != nil {
+		t.Fatal("NewWUID should not be strict by default")
+	}
+	if w3.Next() == 0 {
+		t.Fatal(`w3.Next() == 0`)
+	}
+}
+
+func TestWUID_TryNext(t *testing.T) {
+	w := NewWUID("alpha", nil)
+	atomic.StoreInt64(&w.N, PanicValue)
+	if _, err := w.TryNext(); err == nil {
+		t.Fatal("TryNext should fail instead of panicking")
+	}
+
+	ctx1, cancel1 := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel1()
+	if err := w.WaitLoaded(ctx1); err == nil {
+		t.Fatal("WaitLoaded should fail when ctx is done")
+	}
+}
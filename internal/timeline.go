@@ -0,0 +1,203 @@
+package internal
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const timelineCapacity = 1024
+
+// segment is the part of a block used between two loads. end is -1 for the current segment.
+type segment struct {
+	h28        int64
+	start, end int64
+	startedAt  time.Time
+	endedAt    time.Time
+}
+
+type timeline struct {
+	sync.Mutex
+	segments []segment
+}
+
+// markTimeline closes the current segment at old, the last value of N before the load, and opens
+// a new one at the current N.
+func (w *WUID) markTimeline(h28, old int64) {
+	if w.Flags&12 != 0 {
+		return
+	}
+
+	now := time.Now()
+	tl := &w.timeline
+	tl.Lock()
+	defer tl.Unlock()
+	if k := len(tl.segments); k > 0 && tl.segments[k-1].end < 0 {
+		s := &tl.segments[k-1]
+		s.end, s.endedAt = old&L36Mask, now
+		if s.end < s.start {
+			s.end = s.start
+		}
+	}
+	if len(tl.segments) == timelineCapacity {
+		tl.segments = append(tl.segments[:0], tl.segments[1:]...)
+	}
+	tl.segments = append(tl.segments, segment{
+		h28:       h28,
+		start:     atomic.LoadInt64(&w.N) & L36Mask,
+		end:       -1,
+		startedAt: now,
+	})
+}
+
+// bounds returns the end of s, using the current N and time for the current segment.
+func (w *WUID) bounds(s segment) (int64, time.Time) {
+	if s.end >= 0 {
+		return s.end, s.endedAt
+	}
+	end := atomic.LoadInt64(&w.N) & L36Mask
+	if end < s.start || end >= PanicValue {
+		end = s.start
+	}
+	return end, time.Now()
+}
+
+// CreatedAt returns roughly when id was generated. It is interpolated between the loads of h28 in
+// the current process, unless the timestamp mode or the snowflake mode is on. If id was not generated
+// by w after the last 1024 loads, it is looked up in the provenance store, if any, so that the IDs
+// generated by other processes are covered too. ok is false if id cannot be found either way.
+func (w *WUID) CreatedAt(id int64) (t time.Time, ok bool) {
+	if w.Flags&12 != 0 {
+		return w.Parse(id).Timestamp, true
+	}
+
+	p := w.Parse(id)
+	if t, ok := w.createdAtLocally(p); ok {
+		return t, true
+	}
+	if w.ProvenanceStore != nil {
+		return w.createdAtFromProvenance(p)
+	}
+	return time.Time{}, false
+}
+
+func (w *WUID) createdAtLocally(p ParsedID) (time.Time, bool) {
+	tl := &w.timeline
+	tl.Lock()
+	defer tl.Unlock()
+	for i := len(tl.segments) - 1; i >= 0; i-- {
+		s := tl.segments[i]
+		if s.h28 != p.H28 || p.L36 < s.start {
+			continue
+		}
+		end, endedAt := w.bounds(s)
+		if p.L36 > end {
+			continue
+		}
+		if end == s.start {
+			return s.startedAt, true
+		}
+		d := endedAt.Sub(s.startedAt)
+		return s.startedAt.Add(time.Duration(float64(d) * float64(p.L36-s.start) / float64(end-s.start))), true
+	}
+	return time.Time{}, false
+}
+
+// createdAtFromProvenance estimates the creation time of an ID from the records of its block. Only the
+// times at which the holders took the block are persisted, so the ID is interpolated between its holder
+// and the next one if the block was resumed. Otherwise, the time its holder took the block is returned,
+// which is a lower bound.
+func (w *WUID) createdAtFromProvenance(p ParsedID) (time.Time, bool) {
+	ctx1, cancel1 := context.WithTimeout(context.Background(), w.RenewTimeout)
+	defer cancel1()
+	records, err := w.ProvenanceStore.Lookup(ctx1, p.H28)
+	if err != nil {
+		w.Warnf("<wuid> failed to look up the provenance. name: %s, h28: %d, reason: %+v", w.Name, p.H28, err)
+		return time.Time{}, false
+	}
+
+	var found, next *ProvenanceRecord
+	for i := range records {
+		switch r := &records[i]; {
+		case r.Start <= p.L36:
+			if found == nil || r.Start > found.Start {
+				found = r
+			}
+		case next == nil || r.Start < next.Start:
+			next = r
+		}
+	}
+	switch {
+	case found == nil:
+		return time.Time{}, false
+	case next == nil || !next.Time.After(found.Time):
+		return found.Time, true
+	}
+	d := next.Time.Sub(found.Time)
+	return found.Time.Add(time.Duration(float64(d) * float64(p.L36-found.Start) / float64(next.Start-found.Start))), true
+}
+
+// IDRanges returns the ranges of the IDs generated by w roughly in between [from, to]. Like CreatedAt,
+// it is interpolated between the loads of h28 in the current process, unless the timestamp mode or the
+// snowflake mode is on. With the obfuscation, the range of a whole block is returned instead. Only the
+// last 1024 loads of the current process are covered. The IDs generated by other processes sharing the
+// data source are not, because no store can tell which h28 values they took within a time window.
+func (w *WUID) IDRanges(from, to time.Time) []IDRange {
+	if to.Before(from) {
+		return nil
+	}
+	switch {
+	case w.Flags&8 != 0:
+		lo := (from.UnixNano() - w.Epoch) / int64(time.Millisecond)
+		hi := (to.UnixNano() - w.Epoch) / int64(time.Millisecond)
+		return timestampRange(lo, hi, T41Mask>>22, 22)
+	case w.Flags&4 != 0:
+		lo := (from.UnixNano() - w.Epoch) / w.Unit
+		hi := (to.UnixNano() - w.Epoch) / w.Unit
+		return timestampRange(lo, hi, T23Mask>>40, 40)
+	}
+
+	tl := &w.timeline
+	tl.Lock()
+	defer tl.Unlock()
+	var ranges []IDRange
+	for _, s := range tl.segments {
+		end, endedAt := w.bounds(s)
+		if endedAt.Before(from) || s.startedAt.After(to) {
+			continue
+		}
+		blockLo, blockHi := w.blockRange(s.h28)
+		if w.Flags&1 != 0 {
+			ranges = append(ranges, IDRange{Min: blockLo, Max: blockHi})
+			continue
+		}
+		at := func(t time.Time) int64 {
+			d := endedAt.Sub(s.startedAt)
+			switch {
+			case !t.After(s.startedAt) || d <= 0:
+				return s.start
+			case !t.Before(endedAt):
+				return end
+			}
+			return s.start + int64(float64(end-s.start)*float64(t.Sub(s.startedAt))/float64(d))
+		}
+		ranges = append(ranges, IDRange{Min: blockLo | at(from), Max: blockLo | at(to)})
+	}
+	return ranges
+}
+
+// timestampRange returns the range of the IDs whose timestamp, which starts at bit shift and never
+// exceeds max, is in between [lo, hi].
+func timestampRange(lo, hi, max int64, shift uint) []IDRange {
+	if lo < 0 {
+		lo = 0
+	}
+	if hi > max {
+		hi = max
+	}
+	if lo > hi {
+		return nil
+	}
+	return []IDRange{{Min: lo << shift, Max: hi<<shift | (1<<shift - 1)}}
+}
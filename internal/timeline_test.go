@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestWUID_CreatedAt(t *testing.T) {
+	w := NewWUID("alpha", nil)
+	if err := w.LoadH28(1, nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		w.Next()
+	}
+	startedAt := time.Now().Add(-time.Second * 10)
+	w.timeline.segments[0].startedAt = startedAt
+	if err := w.LoadH28(2, nil); err != nil {
+		t.Fatal(err)
+	}
+	endedAt := w.timeline.segments[0].endedAt
+
+	at, ok := w.CreatedAt(1<<36 | 500)
+	if !ok {
+		t.Fatal("CreatedAt should have succeeded")
+	}
+	if expected := startedAt.Add(endedAt.Sub(startedAt) / 2); at.Sub(expected) > time.Millisecond || expected.Sub(at) > time.Millisecond {
+		t.Fatalf("unexpected creation time. at: %s, expected: %s", at, expected)
+	}
+	if _, ok := w.CreatedAt(1<<36 | 1001); ok {
+		t.Fatal("CreatedAt should have failed")
+	}
+	if _, ok := w.CreatedAt(3 << 36); ok {
+		t.Fatal("CreatedAt should have failed")
+	}
+	if at, ok := w.CreatedAt(w.Next()); !ok || time.Since(at) > time.Second {
+		t.Fatalf("the current block is not handled. at: %s, ok: %v", at, ok)
+	}
+
+	d := endedAt.Sub(startedAt)
+	ranges := w.IDRanges(startedAt.Add(d/5), startedAt.Add(d*2/5))
+	if len(ranges) != 1 {
+		t.Fatalf("there should be 1 range. len(ranges): %d", len(ranges))
+	}
+	if r := ranges[0]; r.Min < 1<<36|199 || r.Min > 1<<36|201 || r.Max < 1<<36|399 || r.Max > 1<<36|401 {
+		t.Fatalf("unexpected range: [%x, %x]", r.Min, r.Max)
+	}
+	if ranges := w.IDRanges(startedAt.Add(-time.Hour), time.Now()); len(ranges) != 2 || ranges[0].Min != 1<<36 {
+		t.Fatalf("unexpected ranges: %v", ranges)
+	}
+	if ranges := w.IDRanges(time.Now(), startedAt); ranges != nil {
+		t.Fatalf("unexpected ranges: %v", ranges)
+	}
+}
+
+func TestWUID_CreatedAt_Snowflake(t *testing.T) {
+	epoch := time.Now().Add(-time.Hour)
+	w := NewWUID("alpha", nil, WithSnowflake(epoch, time.Minute))
+	defer w.Close()
+	if err := w.LeaseWorker(&fakeWorkerLeaser{}, "alpha"); err != nil {
+		t.Fatal(err)
+	}
+	id := w.Next()
+	at, ok := w.CreatedAt(id)
+	if !ok || time.Since(at) > time.Second {
+		t.Fatalf("unexpected creation time. at: %s", at)
+	}
+	ranges := w.IDRanges(at, at)
+	if len(ranges) != 1 || id < ranges[0].Min || id > ranges[0].Max {
+		t.Fatalf("id is not covered. id: %d, ranges: %v", id, ranges)
+	}
+}
+
+func TestWUID_IDRanges_FarFuture(t *testing.T) {
+	epoch := time.Now().Add(-time.Hour)
+	far := time.Unix(0, math.MaxInt64)
+	w1 := NewWUID("alpha", nil, WithSnowflake(epoch, time.Minute))
+	if ranges := w1.IDRanges(epoch, far); len(ranges) != 1 || ranges[0].Min != 0 || ranges[0].Max != math.MaxInt64 {
+		t.Fatalf("the ranges should be clamped to the timestamp field. ranges: %v", ranges)
+	}
+	w2 := NewWUID("alpha", nil, WithTimestamp(epoch, time.Second))
+	if ranges := w2.IDRanges(epoch, far); len(ranges) != 1 || ranges[0].Min != 0 || ranges[0].Max != math.MaxInt64 {
+		t.Fatalf("the ranges should be clamped to the timestamp field. ranges: %v", ranges)
+	}
+	if ranges := w2.IDRanges(far, far); ranges != nil {
+		t.Fatalf("no ID can be generated beyond the timestamp field. ranges: %v", ranges)
+	}
+}
+
+func TestWUID_CreatedAt_Provenance(t *testing.T) {
+	takenAt := time.Now().Add(-time.Hour)
+	m := map[int64][]ProvenanceRecord{
+		7: {{Name: "beta", Time: takenAt}, {Name: "gamma", Time: takenAt.Add(time.Minute), Start: 1000}},
+	}
+	store := ProvenanceFuncs{
+		RecordFunc: func(ctx context.Context, h28 int64, rec ProvenanceRecord) error {
+			return nil
+		},
+		LookupFunc: func(ctx context.Context, h28 int64) ([]ProvenanceRecord, error) {
+			return m[h28], nil
+		},
+	}
+
+	w := NewWUID("alpha", nil, WithProvenance(store, "v1.2.3"))
+	if err := w.LoadH28(5, nil); err != nil {
+		t.Fatal(err)
+	}
+	if at, ok := w.CreatedAt(7<<36 | 500); !ok || !at.Equal(takenAt.Add(time.Second*30)) {
+		t.Fatalf("the ID should be interpolated between the holders. at: %s, ok: %v", at, ok)
+	}
+	if at, ok := w.CreatedAt(7<<36 | 2000); !ok || !at.Equal(takenAt.Add(time.Minute)) {
+		t.Fatalf("the time the last holder took the block should be returned. at: %s, ok: %v", at, ok)
+	}
+	if _, ok := w.CreatedAt(8 << 36); ok {
+		t.Fatal("CreatedAt should have failed")
+	}
+}
@@ -0,0 +1,168 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WorkerLeaser is implemented by the data sources that are able to lease the worker IDs of the snowflake
+// mode. Each lease of a worker ID has a fencing token greater than those of the previous leases. Along with
+// the lease, a horizon is recorded: a timestamp, in milliseconds since the epoch, that no ID made by the
+// holder reaches. A new holder never goes below it, so clock skew between holders cannot make them collide.
+type WorkerLeaser interface {
+	// ClaimWorker takes over the lowest worker ID in between [0, 1024) whose lease has expired or never
+	// existed, bumps its fencing token, and holds it for ttl on behalf of owner. since is the horizon recorded
+	// by the previous holder, and the new horizon is the greater one of since and horizon. ok is false if
+	// every worker ID is held.
+	ClaimWorker(ctx context.Context, owner string, ttl time.Duration, horizon int64) (ok bool, worker, token, since int64, err error)
+	// RenewWorker records horizon and extends the lease of worker to ttl, if token is still its fencing
+	// token. A ttl of 0 releases the lease. ok is false if the worker ID was taken over by another lease.
+	RenewWorker(ctx context.Context, worker, token, horizon int64, ttl time.Duration) (ok bool, err error)
+}
+
+var errSnowflakeH28 = errors.New("h28 cannot be loaded in the snowflake mode. lease a worker ID with LeaseWorker instead")
+
+var errWorkerLost = errors.New("the worker lease is lost")
+
+type workerLease struct {
+	sync.Mutex
+	leaser WorkerLeaser
+	owner  string
+	worker int64
+	token  int64
+}
+
+// LeaseWorker claims a free worker ID from leaser for the lease given to WithSnowflake, and keeps
+// renewing it in the background until w is closed. The worker ID stays the same across renewals.
+// Next panics once the lease expires. It only works in the snowflake mode, and fails if no worker ID
+// is free.
+func (w *WUID) LeaseWorker(leaser WorkerLeaser, owner string) error {
+	if leaser == nil {
+		return errors.New("leaser cannot be nil")
+	}
+	if len(owner) == 0 {
+		return errors.New("owner cannot be empty")
+	}
+	if w.Flags&8 == 0 {
+		return errors.New("the worker ID can only be leased in the snowflake mode")
+	}
+	if w.worker != nil {
+		return errors.New("the worker ID is already leased")
+	}
+
+	start := w.milliseconds()
+	horizon := start + w.Lease
+	ctx1, cancel1 := context.WithTimeout(context.Background(), w.workerTimeout())
+	ok, worker, token, since, err := leaser.ClaimWorker(ctx1, owner, w.leaseTTL(), horizon)
+	cancel1()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("no free worker ID. owner: %s", owner)
+	}
+	if worker < 0 || worker > W10Mask>>12 {
+		return fmt.Errorf("the worker ID is out of range. worker: %d", worker)
+	}
+
+	ms := start
+	if since >= ms {
+		ms = since + 1
+	}
+	w.worker = &workerLease{leaser: leaser, owner: owner, worker: worker, token: token}
+	atomic.StoreInt64(&w.N, ms<<22|worker<<12)
+	atomic.StoreInt64(&w.LeaseDeadline, horizon)
+	w.Lock()
+	w.Renew = w.renewWorker
+	w.Unlock()
+	w.Infof("<wuid> worker leased: %d. name: %s, owner: %s", worker, w.Name, owner)
+	w.markLoaded()
+	go w.keepWorkerLease()
+	return nil
+}
+
+func (w *WUID) leaseTTL() time.Duration {
+	return time.Duration(w.Lease) * time.Millisecond
+}
+
+// workerTimeout bounds each round trip of a worker lease by the renewal interval.
+func (w *WUID) workerTimeout() time.Duration {
+	if d := w.leaseTTL() / 4; d < w.RenewTimeout {
+		return d
+	}
+	return w.RenewTimeout
+}
+
+func (w *WUID) keepWorkerLease() {
+	ticker := time.NewTicker(w.leaseTTL() / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.Done:
+			return
+		case <-ticker.C:
+		}
+		if err := w.renewWorker(); err != nil {
+			w.Warnf("<wuid> failed to renew the worker lease. name: %s, reason: %+v", w.Name, err)
+			if errors.Is(err, errWorkerLost) {
+				return
+			}
+		}
+	}
+}
+
+// renewWorker extends the lease of the worker ID. The deadline is measured from the moment before
+// the round trip, so it never outlives the lease in the backend.
+func (w *WUID) renewWorker() error {
+	l := w.worker
+	l.Lock()
+	defer l.Unlock()
+	if l.token == 0 {
+		return errWorkerLost
+	}
+
+	horizon := w.milliseconds() + w.Lease
+	ctx1, cancel1 := context.WithTimeout(context.Background(), w.workerTimeout())
+	defer cancel1()
+	ok, err := l.leaser.RenewWorker(ctx1, l.worker, l.token, horizon, w.leaseTTL())
+	switch {
+	case err != nil:
+		return err
+	case !ok:
+		atomic.StoreInt64(&w.LeaseDeadline, 0)
+		l.token = 0
+		return fmt.Errorf("%w. it was taken over by another lease. worker: %d", errWorkerLost, l.worker)
+	}
+	if horizon > atomic.LoadInt64(&w.LeaseDeadline) {
+		atomic.StoreInt64(&w.LeaseDeadline, horizon)
+	}
+	return nil
+}
+
+// releaseWorker stops w from making IDs, records the actual horizon and releases the lease.
+func (w *WUID) releaseWorker() {
+	l := w.worker
+	l.Lock()
+	defer l.Unlock()
+	if l.token == 0 {
+		return
+	}
+
+	// An ID made concurrently either uses the current time or the timestamp right after N.
+	atomic.StoreInt64(&w.LeaseDeadline, 0)
+	horizon := atomic.LoadInt64(&w.N) >> 22
+	if now := w.milliseconds(); now > horizon {
+		horizon = now
+	}
+	ctx1, cancel1 := context.WithTimeout(context.Background(), w.RenewTimeout)
+	defer cancel1()
+	if _, err := l.leaser.RenewWorker(ctx1, l.worker, l.token, horizon+1, 0); err != nil {
+		w.Warnf("<wuid> failed to release the worker lease. name: %s, worker: %d, reason: %+v", w.Name, l.worker, err)
+	}
+	l.token = 0
+}
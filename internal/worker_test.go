@@ -0,0 +1,181 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeWorker struct {
+	owner     string
+	token     int64
+	horizon   int64
+	expiresAt time.Time
+}
+
+type fakeWorkerLeaser struct {
+	sync.Mutex
+	workers [1024]fakeWorker
+	err     error
+}
+
+func (l *fakeWorkerLeaser) claim(owner string, ttl time.Duration) (bool, int64, int64, int64) {
+	for i := range l.workers {
+		x := &l.workers[i]
+		if time.Now().Before(x.expiresAt) {
+			continue
+		}
+		x.owner, x.token, x.expiresAt = owner, x.token+1, time.Now().Add(ttl)
+		return true, int64(i), x.token, x.horizon
+	}
+	return false, 0, 0, 0
+}
+
+func (l *fakeWorkerLeaser) ClaimWorker(ctx context.Context, owner string, ttl time.Duration, horizon int64) (bool, int64, int64, int64, error) {
+	l.Lock()
+	defer l.Unlock()
+	if l.err != nil {
+		return false, 0, 0, 0, l.err
+	}
+	ok, worker, token, since := l.claim(owner, ttl)
+	if ok && horizon > since {
+		l.workers[worker].horizon = horizon
+	}
+	return ok, worker, token, since, nil
+}
+
+func (l *fakeWorkerLeaser) RenewWorker(ctx context.Context, worker, token, horizon int64, ttl time.Duration) (bool, error) {
+	l.Lock()
+	defer l.Unlock()
+	if l.err != nil {
+		return false, l.err
+	}
+	x := &l.workers[worker]
+	if x.token != token {
+		return false, nil
+	}
+	x.horizon, x.expiresAt = horizon, time.Now().Add(ttl)
+	return true, nil
+}
+
+func (l *fakeWorkerLeaser) set(f func()) {
+	l.Lock()
+	defer l.Unlock()
+	f()
+}
+
+func TestWUID_LeaseWorker(t *testing.T) {
+	leaser := &fakeWorkerLeaser{}
+	w1 := NewWUID("alpha", nil, WithSnowflake(time.Now(), time.Second*2))
+	if err := w1.LeaseWorker(leaser, "alpha"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w1.LeaseWorker(leaser, "alpha"); err == nil {
+		t.Fatal("LeaseWorker should fail when the worker ID is already leased")
+	}
+	w2 := NewWUID("alpha", nil, WithSnowflake(time.Now(), time.Second*2))
+	defer w2.Close()
+	if err := w2.LeaseWorker(leaser, "beta"); err != nil {
+		t.Fatal(err)
+	}
+	if w1.Parse(w1.Next()).Worker != 0 || w2.Parse(w2.Next()).Worker != 1 {
+		t.Fatal("a leased worker ID should never be shared")
+	}
+
+	time.Sleep(time.Second * 3)
+	if p := w1.Parse(w1.Next()); p.Worker != 0 {
+		t.Fatalf("the worker ID should stay the same across renewals. worker: %d", p.Worker)
+	}
+	if p := w2.Parse(w2.Next()); p.Worker != 1 {
+		t.Fatalf("the worker ID should stay the same across renewals. worker: %d", p.Worker)
+	}
+
+	last := w1.Next()
+	w1.Close()
+	func() {
+		defer func() {
+			_ = recover()
+		}()
+		w1.Next()
+		t.Fatal("Next should have panicked after the worker lease was released")
+	}()
+	if h := leaser.workers[0].horizon; h <= last>>22 || h > last>>22+1000 {
+		t.Fatalf("the horizon is not recorded as expected. horizon: %d, last: %d", h, last>>22)
+	}
+
+	// The clock of w3 is 10 seconds behind, but its IDs still go after those of w1.
+	w3 := NewWUID("alpha", nil, WithSnowflake(time.Now().Add(time.Second*10), time.Minute))
+	defer w3.Close()
+	if err := w3.LeaseWorker(leaser, "gamma"); err != nil {
+		t.Fatal(err)
+	}
+	if v := w3.Next(); v <= last || w3.Parse(v).Worker != 0 {
+		t.Fatalf("the new holder should go past the horizon. v: %d, last: %d", v, last)
+	}
+}
+
+func TestWUID_LeaseWorker_Error(t *testing.T) {
+	leaser := &fakeWorkerLeaser{}
+	w := NewWUID("alpha", nil)
+	if err := w.LeaseWorker(leaser, "alpha"); err == nil {
+		t.Fatal("LeaseWorker should fail when the snowflake mode is off")
+	}
+
+	w = NewWUID("alpha", nil, WithSnowflake(time.Now(), time.Minute))
+	defer w.Close()
+	if err := w.LeaseWorker(nil, "alpha"); err == nil {
+		t.Fatal("LeaseWorker should fail when leaser is nil")
+	}
+	if err := w.LeaseWorker(leaser, ""); err == nil {
+		t.Fatal("LeaseWorker should fail when owner is empty")
+	}
+	for i := 0; i < 1024; i++ {
+		leaser.claim("beta", time.Minute)
+	}
+	if err := w.LeaseWorker(leaser, "alpha"); err == nil {
+		t.Fatal("LeaseWorker should fail when there is no free worker ID")
+	}
+	leaser.err = errors.New("foo")
+	if err := w.LeaseWorker(leaser, "alpha"); err == nil {
+		t.Fatal("LeaseWorker should fail when the leaser fails")
+	}
+}
+
+func TestWUID_LeaseWorker_Lost(t *testing.T) {
+	leaser := &fakeWorkerLeaser{}
+	w := NewWUID("alpha", nil, WithSnowflake(time.Now(), time.Minute))
+	defer w.Close()
+	if err := w.LeaseWorker(leaser, "alpha"); err != nil {
+		t.Fatal(err)
+	}
+	w.Next()
+
+	leaser.set(func() {
+		leaser.err = errors.New("foo")
+	})
+	if err := w.RenewNow(); err == nil {
+		t.Fatal("RenewNow should fail when the leaser fails")
+	}
+	w.Next()
+
+	leaser.set(func() {
+		leaser.err = nil
+		leaser.workers[0].token++
+	})
+	if err := w.RenewNow(); err == nil {
+		t.Fatal("RenewNow should fail when the worker lease is taken over")
+	}
+	if atomic.LoadInt64(&w.LeaseDeadline) != 0 {
+		t.Fatal("the deadline should be cleared when the worker lease is taken over")
+	}
+	func() {
+		defer func() {
+			_ = recover()
+		}()
+		w.Next()
+		t.Fatal("Next should have panicked after the worker lease was taken over")
+	}()
+}
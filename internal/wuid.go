@@ -4,8 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"github.com/edwingeng/slog"
+	"runtime"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 const (
@@ -26,6 +29,53 @@ const (
 	L36Mask = 0x0FFFFFFFFF
 )
 
+const (
+	// TimestampPanicValue is the PanicValue of the timestamp mode.
+	TimestampPanicValue int64 = ((1 << 20) * 96 / 100) & ^1023
+	// TimestampCriticalValue is the CriticalValue of the timestamp mode.
+	TimestampCriticalValue int64 = ((1 << 20) * 80 / 100) & ^1023
+	// TimestampRenewIntervalMask is the RenewIntervalMask of the timestamp mode.
+	TimestampRenewIntervalMask int64 = 0x8000 - 1
+)
+
+// In the timestamp mode, a generated number is composed of a 23-bit timestamp,
+// a 20-bit h28 and a 20-bit counter. The counter restarts from zero whenever
+// the timestamp moves forward.
+const (
+	T23Mask = 0x7FFFFF << 40
+	H20Mask = 0x0FFFFF << 20
+	L20Mask = 0x0FFFFF
+)
+
+// In the snowflake mode, a generated number is composed of a 41-bit timestamp in milliseconds,
+// a 10-bit worker ID and a 12-bit sequence. The worker ID is leased by LeaseWorker.
+const (
+	T41Mask = 0x01FFFFFFFFFF << 22
+	W10Mask = 0x03FF << 12
+	S12Mask = 0x0FFF
+)
+
+// CoarseClockInterval indicates how often the clock of the timestamp mode ticks.
+const CoarseClockInterval = time.Millisecond * 100
+
+var coarseClock struct {
+	sync.Once
+	now int64
+}
+
+func startCoarseClock() {
+	coarseClock.Do(func() {
+		atomic.StoreInt64(&coarseClock.now, time.Now().UnixNano())
+		go func() {
+			for t := range time.Tick(CoarseClockInterval) {
+				if now := t.UnixNano(); now > atomic.LoadInt64(&coarseClock.now) {
+					atomic.StoreInt64(&coarseClock.now, now)
+				}
+			}
+		}()
+	})
+}
+
 type WUID struct {
 	N     int64
 	Step  int64
@@ -36,13 +86,51 @@ type WUID struct {
 	Monolithic      bool
 	ObfuscationMask int64
 	Section         int64
+	SectionBits     int64
+	Residue         int64
+
+	Epoch int64
+	Unit  int64
+
+	Lease         int64
+	LeaseDeadline int64
 
 	slog.Logger
 	Name        string
 	H28Verifier func(h28 int64) error
 
 	sync.Mutex
-	Renew func() error
+	Renew        func() error
+	RenewTimeout time.Duration
+
+	MinID          int64
+	ExcludedRanges []IDRange
+
+	Journal      *Journal
+	Reserve      *Reserve
+	EventHandler func(e Event)
+
+	HeadroomThresholds []float64
+	forecast           forecastState
+	timeline           timeline
+
+	ProvenanceStore ProvenanceStore
+	Version         string
+
+	Halted          int32
+	SectionH28Floor int64
+	section         *sectionLease
+	Checkpoint      int64
+	blocks          *blockLease
+	worker          *workerLease
+	Done            chan struct{}
+	closeOnce       sync.Once
+
+	Strict     bool
+	Loaded     chan struct{}
+	loadedOnce sync.Once
+
+	optionErrors []error
 
 	Stats struct {
 		NumRenewAttempts int64
@@ -50,8 +138,17 @@ type WUID struct {
 	}
 }
 
-func NewWUID(name string, logger slog.Logger, opts ...Option) (w *WUID) {
-	w = &WUID{Step: 1, Name: name, Monolithic: true}
+func NewWUID(name string, logger slog.Logger, opts ...Option) *WUID {
+	w, err := newWUID(name, logger, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return w
+}
+
+func newWUID(name string, logger slog.Logger, opts ...Option) (*WUID, error) {
+	w := &WUID{Step: 1, Name: name, Monolithic: true, RenewTimeout: DefaultRenewTimeout,
+		Done: make(chan struct{}), Loaded: make(chan struct{})}
 	if logger != nil {
 		w.Logger = logger
 	} else {
@@ -60,28 +157,168 @@ func NewWUID(name string, logger slog.Logger, opts ...Option) (w *WUID) {
 	for _, opt := range opts {
 		opt(w)
 	}
-	if !w.Obfuscation || w.Floor == 0 {
-		return
+	if w.Flags&4 != 0 && !w.Monolithic {
+		w.optionErrorf("WithTimestamp and WithSection cannot be used together")
+	}
+	if w.Flags&8 != 0 {
+		if w.Flags != 8 || w.Step != 1 || !w.Monolithic {
+			w.optionErrorf("WithSnowflake cannot be used together with other options")
+		}
+		if w.H28Verifier != nil {
+			w.optionErrorf("WithSnowflake and WithH28Verifier cannot be used together")
+		}
+		if w.Reserve != nil {
+			w.optionErrorf("WithSnowflake and WithReserve cannot be used together")
+		}
+		if w.ProvenanceStore != nil {
+			w.optionErrorf("WithSnowflake and WithProvenance cannot be used together")
+		}
+	}
+	if (w.MinID > 0 || len(w.ExcludedRanges) > 0) && w.Flags&12 != 0 {
+		w.optionErrorf("WithMinID and WithExcludedRanges cannot be used together with WithTimestamp or WithSnowflake")
+	}
+	if w.Flags&16 != 0 && w.Flags != 16 {
+		w.optionErrorf("WithModulo cannot be used together with WithObfuscation, WithTimestamp or WithSnowflake")
+	}
+	if len(w.optionErrors) > 0 {
+		return nil, &OptionError{Errors: w.optionErrors}
 	}
 
-	ones := w.Step - 1
-	w.ObfuscationMask |= ones
-	return
+	if w.Strict {
+		w.halt(HaltNotLoaded)
+	}
+	if w.Obfuscation && w.Floor != 0 {
+		ones := w.Step - 1
+		w.ObfuscationMask |= ones
+	}
+	if w.Flags&4 != 0 {
+		w.ObfuscationMask &= L20Mask
+		startCoarseClock()
+	}
+	return w, nil
 }
 
 func (w *WUID) Next() int64 {
+	if h := atomic.LoadInt32(&w.Halted); h != 0 {
+		panic(haltReason(h))
+	}
+	if w.Flags&12 != 0 {
+		if w.Flags&8 != 0 {
+			return w.nextSnowflake()
+		}
+		return w.nextWithTimestamp()
+	}
+
 	v1 := atomic.AddInt64(&w.N, w.Step)
+	if c := atomic.LoadInt64(&w.Checkpoint); c != 0 {
+		if c-v1 < BlockWindow/2 {
+			w.kickBlockLease()
+		}
+		if v1 >= c {
+			panic(errors.New("the block checkpoint has not been saved in time"))
+		}
+	}
 	v2 := v1 & L36Mask
 	if v2 >= PanicValue {
 		panicValue := v1&H28Mask | PanicValue
 		atomic.CompareAndSwapInt64(&w.N, v1, panicValue)
 		panic(fmt.Errorf("the low 36 bits are about to run out"))
 	}
-	if v2 >= CriticalValue && v2&RenewIntervalMask == 0 {
+	if v2 >= CriticalValue && v2&RenewIntervalMask < w.Step {
 		go renewImpl(w)
 	}
+	return w.render(v1)
+}
+
+// NextWithTag returns a unique identifier whose low log2(step) bits are tag, where step is set
+// by WithStep. tag must be in between [0, step). It cannot work with a floor or WithModulo.
+func (w *WUID) NextWithTag(tag int64) int64 {
+	if w.Flags&(2|8|16) != 0 {
+		panic("tags cannot work with the floor, the snowflake mode or the modulo mode")
+	}
+	if tag < 0 || tag >= w.Step {
+		panic(fmt.Errorf("tag must be in between [0, %d)", w.Step))
+	}
+	return w.Next()&^(w.Step-1) | tag
+}
+
+func (w *WUID) nextWithTimestamp() int64 {
+	ts := w.timestamp()
+	for {
+		v0 := atomic.LoadInt64(&w.N)
+		if v0>>40 >= ts {
+			break
+		}
+		if atomic.CompareAndSwapInt64(&w.N, v0, ts<<40|v0&H20Mask) {
+			break
+		}
+	}
 
-	switch w.Flags {
+	v1 := atomic.AddInt64(&w.N, w.Step)
+	v2 := v1 & L20Mask
+	if v2 >= TimestampPanicValue {
+		panicValue := v1&^L20Mask | TimestampPanicValue
+		atomic.CompareAndSwapInt64(&w.N, v1, panicValue)
+		panic(fmt.Errorf("the low 20 bits are about to run out"))
+	}
+	if v2 >= TimestampCriticalValue && v2&TimestampRenewIntervalMask == 0 {
+		go renewImpl(w)
+	}
+	return w.render(v1)
+}
+
+func (w *WUID) nextSnowflake() int64 {
+	for {
+		now := w.milliseconds()
+		deadline := atomic.LoadInt64(&w.LeaseDeadline)
+		if now >= deadline {
+			panic(fmt.Errorf("the worker lease is not held"))
+		}
+		if now > T41Mask>>22 {
+			panic(fmt.Errorf("the timestamp is out of range"))
+		}
+
+		var v1 int64
+		v0 := atomic.LoadInt64(&w.N)
+		switch ms := v0 >> 22; {
+		case now > ms:
+			v1 = now<<22 | v0&W10Mask
+		case v0&S12Mask < S12Mask:
+			v1 = v0 + 1
+		case now == ms:
+			runtime.Gosched()
+			continue
+		default:
+			v1 = (ms+1)<<22 | v0&W10Mask
+		}
+		if v1>>22 >= deadline {
+			// No ID may reach the horizon recorded in the lease. Wait for a renewal.
+			runtime.Gosched()
+			continue
+		}
+		if atomic.CompareAndSwapInt64(&w.N, v0, v1) {
+			return v1
+		}
+	}
+}
+
+func (w *WUID) milliseconds() int64 {
+	return (time.Now().UnixNano() - w.Epoch) / int64(time.Millisecond)
+}
+
+func (w *WUID) timestamp() int64 {
+	ts := (atomic.LoadInt64(&coarseClock.now) - w.Epoch) / w.Unit
+	if ts < 0 {
+		return 0
+	}
+	if ts > T23Mask>>40 {
+		panic(fmt.Errorf("the timestamp is out of range"))
+	}
+	return ts
+}
+
+func (w *WUID) render(v1 int64) int64 {
+	switch w.Flags & 3 {
 	case 0:
 		return v1
 	case 1:
@@ -135,13 +372,25 @@ func (w *WUID) Reset(n int64) {
 		panic("n is too old")
 	}
 
-	if w.Monolithic {
+	if w.Flags&8 != 0 {
+		panic("Reset cannot be used in the snowflake mode, where the worker ID is leased by LeaseWorker")
+	}
+
+	if w.Flags&4 != 0 {
+		if n&L36Mask >= TimestampPanicValue {
+			panic("n is too old")
+		}
+		n = w.timestamp()<<40 | n>>36<<20 | n&L20Mask
+	} else if w.Monolithic {
 		// Empty
 	} else {
-		const L60Mask = 0x0FFFFFFFFFFFFFFF
-		n = n&L60Mask | w.Section
+		mask := int64(1)<<(63-w.SectionBits) - 1
+		n = n&mask | w.Section
 	}
-	if w.Floor > 1 {
+	if w.Flags&16 != 0 {
+		n += (w.Residue - n%w.Step + w.Step) % w.Step
+		atomic.StoreInt64(&w.N, n)
+	} else if w.Floor > 1 {
 		if n&(w.Step-1) == 0 {
 			atomic.StoreInt64(&w.N, n)
 		} else {
@@ -152,32 +401,100 @@ func (w *WUID) Reset(n int64) {
 	}
 }
 
+// LoadH28 verifies h28, which is acquired from src, and uses it as the high 28 bits. In addition,
+// src is saved for future renewal if there is none yet.
+func (w *WUID) LoadH28(h28 int64, src H28Source) error {
+	return w.loadH28(h28, src, false)
+}
+
+// loadH28 skips the journal if h28 comes from the reserve, which is recorded on reservation.
+func (w *WUID) loadH28(h28 int64, src H28Source, reserved bool) error {
+	if w.Flags&8 != 0 {
+		return errSnowflakeH28
+	}
+	if err := w.verifyH28(h28, src); err != nil {
+		return err
+	}
+	if w.Strict {
+		if err := w.claim(journalKey(w.Name, src)); err != nil {
+			return err
+		}
+	}
+	if w.Journal != nil && !reserved {
+		if err := w.Journal.Record(recordKey(w.Name, src, h28), h28); err != nil {
+			if errors.Is(err, ErrRollback) {
+				w.Emit(Event{Kind: EventRollback, Name: w.Name, H28: h28, Err: err})
+			}
+			return err
+		}
+	}
+	if w.section != nil {
+		if err := w.recordSectionH28(h28); err != nil {
+			return err
+		}
+	}
+
+	var start int64
+	if w.blocks != nil {
+		var err error
+		if start, err = w.enterBlock(h28); err != nil {
+			return err
+		}
+	}
+	old := atomic.LoadInt64(&w.N)
+	w.Reset(h28<<36 | start)
+	w.markTimeline(h28, old)
+	w.Infof("<wuid> new h28: %d. name: %s", h28, w.Name)
+	w.trackH28(h28)
+	if w.ProvenanceStore != nil {
+		w.recordProvenance(h28, start)
+	}
+	w.markLoaded()
+
+	w.Lock()
+	defer w.Unlock()
+
+	if w.Renew == nil {
+		w.Renew = func() error {
+			return w.LoadH28FromSource(src)
+		}
+	}
+	return nil
+}
+
 func (w *WUID) VerifyH28(h28 int64) error {
 	if h28 <= 0 {
 		return errors.New("h28 must be positive")
 	}
 
-	if w.Monolithic {
-		if h28 > 0x07FFFFFF {
-			return errors.New("h28 should not exceed 0x07FFFFFF")
-		}
-	} else {
-		if h28 > 0x00FFFFFF {
-			return errors.New("h28 should not exceed 0x00FFFFFF")
-		}
+	if limit := w.h28Limit(); h28 > limit {
+		return fmt.Errorf("h28 should not exceed 0x%08X", limit)
 	}
 
-	current := atomic.LoadInt64(&w.N) >> 36
-	if w.Monolithic {
+	n := atomic.LoadInt64(&w.N)
+	current := n >> 36
+	if w.Flags&4 != 0 {
+		if h28 == n&H20Mask>>20 {
+			return fmt.Errorf("h28 should be a different value other than %d", h28)
+		}
+	} else if w.Monolithic {
 		if h28 == current {
 			return fmt.Errorf("h28 should be a different value other than %d", h28)
 		}
 	} else {
-		if h28 == current&0x00FFFFFF {
+		if h28 == current&w.sectionH28Limit() {
 			return fmt.Errorf("h28 should be a different value other than %d", h28)
 		}
 	}
 
+	if err := w.checkExclusions(h28); err != nil {
+		return err
+	}
+
+	if floor := atomic.LoadInt64(&w.SectionH28Floor); h28 <= floor {
+		return fmt.Errorf("h28 should be greater than %d, the highest h28 ever used in the section", floor)
+	}
+
 	if w.H28Verifier != nil {
 		if err := w.H28Verifier(h28); err != nil {
 			return err
@@ -187,36 +504,154 @@ func (w *WUID) VerifyH28(h28 int64) error {
 	return nil
 }
 
+func (w *WUID) sectionH28Limit() int64 {
+	return int64(1)<<(27-w.SectionBits) - 1
+}
+
+// Close stops all the background activities of w.
+func (w *WUID) Close() {
+	w.closeOnce.Do(func() {
+		if w.blocks != nil {
+			w.releaseBlock()
+		}
+		if w.worker != nil {
+			w.releaseWorker()
+		}
+		close(w.Done)
+		w.releaseClaims()
+	})
+}
+
+// ParsedID holds the fields decoded from a generated number.
+type ParsedID struct {
+	Section   int64
+	H28       int64
+	L36       int64
+	Timestamp time.Time
+	Worker    int64
+	Sequence  int64
+	Shard     int64
+	Tag       int64
+}
+
+// Parse decodes a number generated by w. In the timestamp mode, L36 holds the low 20 bits.
+// In the snowflake mode, only Timestamp, Worker and Sequence are filled.
+func (w *WUID) Parse(id int64) (p ParsedID) {
+	if w.Flags&8 != 0 {
+		p.Timestamp = time.Unix(0, w.Epoch+id>>22*int64(time.Millisecond))
+		p.Worker = id & W10Mask >> 12
+		p.Sequence = id & S12Mask
+		return
+	}
+	if w.Flags&(2|16) == 0 {
+		p.Tag = id & (w.Step - 1)
+	}
+	if w.Flags&1 != 0 {
+		x := id ^ w.ObfuscationMask
+		id = id&^L36Mask | x&L36Mask
+	}
+	if w.Flags&4 != 0 {
+		p.Timestamp = time.Unix(0, w.Epoch+id>>40*w.Unit)
+		p.H28 = id & H20Mask >> 20
+		p.L36 = id & L20Mask
+		return
+	}
+	if w.Monolithic {
+		p.H28 = id >> 36
+	} else {
+		p.Section = id >> (63 - w.SectionBits)
+		p.H28 = id >> 36 & w.sectionH28Limit()
+	}
+	p.L36 = id & L36Mask
+	if w.Flags&16 != 0 {
+		p.Shard = w.ShardOf(id)
+	}
+	return
+}
+
+// ShardOf returns the shard that id belongs to in the modulo mode.
+func (w *WUID) ShardOf(id int64) int64 {
+	if w.Flags&16 == 0 {
+		return 0
+	}
+	return id % w.Step
+}
+
 type Option func(w *WUID)
 
+// OptionError collects all the invalid options passed to a constructor.
+type OptionError struct {
+	Errors []error
+}
+
+func (e *OptionError) Error() string {
+	a := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		a[i] = err.Error()
+	}
+	return "invalid options: " + strings.Join(a, "; ")
+}
+
+func (w *WUID) optionErrorf(format string, args ...interface{}) {
+	w.optionErrors = append(w.optionErrors, fmt.Errorf(format, args...))
+}
+
+// invalidOption returns an Option that reports an error when it is applied.
+func invalidOption(format string, args ...interface{}) Option {
+	return func(w *WUID) {
+		w.optionErrorf(format, args...)
+	}
+}
+
+// WithH28Verifier adds an extra verifier for the high 28 bits.
 func WithH28Verifier(cb func(h28 int64) error) Option {
 	return func(w *WUID) {
 		w.H28Verifier = cb
 	}
 }
 
+// WithSection brands a section ID on each generated number. A section ID must be in between [0, 7].
 func WithSection(section int8) Option {
 	if section < 0 || section > 7 {
-		panic("section must be in between [0, 7]")
+		return invalidOption("WithSection: section must be in between [0, 7]")
 	}
 	return func(w *WUID) {
 		w.Monolithic = false
 		w.Section = int64(section) << 60
+		w.SectionBits = 3
 	}
 }
 
+// WithSectionBits brands a section ID on each generated number, using the highest bits bits other than the sign bit.
+// bits must be in between [1, 16], and h28 should not exceed 1<<(27-bits)-1.
+func WithSectionBits(bits int8, section int64) Option {
+	if bits < 1 || bits > 16 {
+		return invalidOption("WithSectionBits: bits must be in between [1, 16]")
+	}
+	if section < 0 || section >= 1<<bits {
+		return invalidOption("WithSectionBits: section must be in between [0, %d]", 1<<bits-1)
+	}
+	return func(w *WUID) {
+		w.Monolithic = false
+		w.Section = section << (63 - bits)
+		w.SectionBits = int64(bits)
+	}
+}
+
+// WithStep sets the step and the floor for each generated number.
 func WithStep(step int64, floor int64) Option {
 	switch step {
 	case 1, 2, 4, 8, 16, 32, 64, 128, 256, 512, 1024:
 	default:
-		panic("the step must be one of these values: 1, 2, 4, 8, 16, 32, 64, 128, 256, 512, 1024")
+		return invalidOption("WithStep: the step must be one of these values: 1, 2, 4, 8, 16, 32, 64, 128, 256, 512, 1024")
 	}
 	if floor != 0 && (floor < 0 || floor >= step) {
-		panic(fmt.Errorf("floor must be in between [0, %d)", step))
+		return invalidOption("WithStep: floor must be in between [0, %d)", step)
 	}
 	return func(w *WUID) {
 		if w.Step != 1 {
-			panic("a second WithStep detected")
+			w.optionErrorf("a second WithStep detected")
+			return
 		}
 		w.Step = step
 		if floor >= 2 {
@@ -226,9 +661,10 @@ func WithStep(step int64, floor int64) Option {
 	}
 }
 
+// WithObfuscation enables number obfuscation.
 func WithObfuscation(seed int) Option {
 	if seed == 0 {
-		panic("seed cannot be zero")
+		return invalidOption("WithObfuscation: seed cannot be zero")
 	}
 	return func(w *WUID) {
 		w.Obfuscation = true
@@ -240,3 +676,50 @@ func WithObfuscation(seed int) Option {
 		w.Flags |= 1
 	}
 }
+
+// WithTimestamp embeds a coarse timestamp, which is counted in unit since epoch, in each generated number.
+// The generated numbers are ordered by the timestamp across instances, and h28 should not exceed 0x000FFFFF.
+func WithTimestamp(epoch time.Time, unit time.Duration) Option {
+	if unit < time.Second {
+		return invalidOption("WithTimestamp: unit cannot be less than a second")
+	}
+	return func(w *WUID) {
+		w.Epoch = epoch.UnixNano()
+		w.Unit = int64(unit)
+		w.Flags |= 4
+	}
+}
+
+// WithSnowflake switches to the Snowflake layout: a 41-bit timestamp in milliseconds since epoch, a 10-bit
+// worker ID and a 12-bit sequence. The worker ID is leased by LeaseWorker for the duration lease instead of
+// loading h28. All the generators sharing the worker IDs must use the same epoch.
+func WithSnowflake(epoch time.Time, lease time.Duration) Option {
+	if lease < time.Second {
+		return invalidOption("WithSnowflake: lease cannot be less than a second")
+	}
+	return func(w *WUID) {
+		w.Epoch = epoch.UnixNano()
+		w.Lease = int64(lease / time.Millisecond)
+		w.Flags |= 8
+	}
+}
+
+// WithModulo makes each generated number satisfy id%n == k, which works like auto_increment_increment
+// and auto_increment_offset of MySQL. n must be in between [2, 1024].
+func WithModulo(n, k int64) Option {
+	if n < 2 || n > 1024 {
+		return invalidOption("WithModulo: n must be in between [2, 1024]")
+	}
+	if k < 0 || k >= n {
+		return invalidOption("WithModulo: k must be in between [0, %d)", n)
+	}
+	return func(w *WUID) {
+		if w.Step != 1 {
+			w.optionErrorf("WithModulo cannot be used together with WithStep")
+			return
+		}
+		w.Step = n
+		w.Residue = k
+		w.Flags |= 16
+	}
+}
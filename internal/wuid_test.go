@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"errors"
 	"github.com/edwingeng/slog"
 	"math/rand"
@@ -389,7 +390,7 @@ func TestWithSection_Panic(t *testing.T) {
 			defer func() {
 				_ = recover()
 			}()
-			WithSection(j)
+			NewWUID("alpha", slog.NewDumbLogger(), WithSection(j))
 			if j >= 8 {
 				t.Fatalf("WithSection should only accept the values in [0, 7]. j: %d", j)
 			}
@@ -429,6 +430,33 @@ func TestWithSection_Reset(t *testing.T) {
 	}()
 }
 
+func TestWUID_LoadH28(t *testing.T) {
+	w := NewWUID("alpha", nil)
+	var numCalls int
+	src := H28SourceFunc(func(ctx context.Context) (int64, error) {
+		numCalls++
+		return 12, nil
+	})
+	if err := w.LoadH28(0, src); err == nil {
+		t.Fatal("LoadH28 should fail when h28 is invalid")
+	}
+	if w.Renew != nil {
+		t.Fatal(`w.Renew != nil`)
+	}
+	if err := w.LoadH28(10, src); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt64(&w.N) != 10<<36 {
+		t.Fatal(`atomic.LoadInt64(&w.N) != 10<<36`)
+	}
+	if err := w.LoadH28(11, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.RenewNow(); err != nil || numCalls != 1 {
+		t.Fatal("the first renew function should be kept")
+	}
+}
+
 func TestWithH28Verifier(t *testing.T) {
 	w := NewWUID("alpha", nil, WithH28Verifier(func(h28 int64) error {
 		if h28 >= 20 {
@@ -520,3 +548,414 @@ func TestWithObfuscation(t *testing.T) {
 		t.Fatal("WithObfuscation should have panicked")
 	}()
 }
+
+func TestWithTimestamp(t *testing.T) {
+	epoch := time.Now().Add(-time.Hour - time.Second*30)
+	w := NewWUID("alpha", nil, WithTimestamp(epoch, time.Minute), WithObfuscation(1))
+	w.Reset(5 << 36)
+
+	var last int64
+	for i := int64(1); i < 100; i++ {
+		id := w.Next()
+		if id <= last&^L20Mask {
+			t.Fatal(`id <= last&^L20Mask`)
+		}
+		last = id
+		p := w.Parse(id)
+		if p.H28 != 5 || p.L36 != i {
+			t.Fatalf("Parse does not work as expected. h28: %d, l36: %d, i: %d", p.H28, p.L36, i)
+		}
+		if d := time.Since(p.Timestamp); d < 0 || d > time.Minute+CoarseClockInterval {
+			t.Fatalf("the timestamp is out of expectation. d: %v", d)
+		}
+	}
+
+	w.Epoch -= int64(time.Minute)
+	id := w.Next()
+	if id>>40 != last>>40+1 {
+		t.Fatal(`id>>40 != last>>40+1`)
+	}
+	if p := w.Parse(id); p.H28 != 5 || p.L36 != 1 {
+		t.Fatalf("the counter should restart. h28: %d, l36: %d", p.H28, p.L36)
+	}
+
+	if err := w.VerifyH28(0x000FFFFF); err != nil {
+		t.Fatalf("VerifyH28 does not work as expected. n: 0x000FFFFF, error: %s", err)
+	}
+	if err := w.VerifyH28(0x00100000); err == nil {
+		t.Fatalf("VerifyH28 does not work as expected. n: 0x00100000")
+	}
+	if err := w.VerifyH28(5); err == nil {
+		t.Fatalf("VerifyH28 does not work as expected. n: 5")
+	}
+
+	func() {
+		defer func() {
+			_ = recover()
+		}()
+		NewWUID("alpha", nil, WithTimestamp(epoch, time.Minute), WithSection(1))
+		t.Fatal("NewWUID should have panicked")
+	}()
+
+	func() {
+		defer func() {
+			_ = recover()
+		}()
+		NewWUID("alpha", nil, WithTimestamp(epoch, time.Millisecond))
+		t.Fatal("WithTimestamp should have panicked")
+	}()
+}
+
+func TestWithTimestamp_FarEpoch(t *testing.T) {
+	epoch := time.Now().Add(-time.Second * (1<<20 + 100))
+	w := NewWUID("alpha", nil, WithTimestamp(epoch, time.Second))
+	w.Reset(5 << 36)
+
+	var last int64
+	for i := int64(1); i < 100; i++ {
+		id := w.Next()
+		if id <= last {
+			t.Fatalf("id should be positive and increasing. id: %d, last: %d", id, last)
+		}
+		last = id
+		p := w.Parse(id)
+		if p.H28 != 5 {
+			t.Fatalf("Parse does not work as expected. h28: %d", p.H28)
+		}
+		if d := time.Since(p.Timestamp); d < 0 || d > time.Second+CoarseClockInterval {
+			t.Fatalf("the timestamp is out of expectation. d: %v", d)
+		}
+	}
+
+	r := w.IDRanges(time.Now().Add(-time.Minute), time.Now().Add(time.Minute))
+	if len(r) != 1 || r[0].Min > last || r[0].Max < last {
+		t.Fatalf("IDRanges does not work as expected. ranges: %v, id: %d", r, last)
+	}
+}
+
+func TestWithTimestamp_Renew(t *testing.T) {
+	w := NewWUID("alpha", slog.NewScavenger(), WithTimestamp(time.Now(), time.Hour))
+	w.Renew = func() error {
+		w.Reset((w.Parse(atomic.LoadInt64(&w.N)).H28 + 1) << 36)
+		return nil
+	}
+
+	const bye = ((TimestampCriticalValue + TimestampRenewIntervalMask) & ^TimestampRenewIntervalMask) - 1
+	w.Reset(1<<36 | bye)
+	w.Next()
+	waitUntilNumRenewedReaches(t, w, 1)
+	if p := w.Parse(w.Next()); p.H28 != 2 || p.L36 != 1 {
+		t.Fatalf("the renew mechanism does not work as expected. h28: %d, l36: %d", p.H28, p.L36)
+	}
+
+	w.Reset(2<<36 | TimestampPanicValue - 1)
+	func() {
+		defer func() {
+			_ = recover()
+		}()
+		w.Next()
+		t.Fatal("Next should have panicked")
+	}()
+}
+
+func TestWithSnowflake(t *testing.T) {
+	epoch := time.Now().Add(-time.Hour)
+	w := NewWUID("alpha", slog.NewScavenger(), WithSnowflake(epoch, time.Minute))
+	defer w.Close()
+	func() {
+		defer func() {
+			_ = recover()
+		}()
+		w.Next()
+		t.Fatal("Next should have panicked before the worker lease is acquired")
+	}()
+
+	leaser := &fakeWorkerLeaser{}
+	for i := int64(0); i < 5; i++ {
+		leaser.claim("beta", time.Minute)
+	}
+	if err := w.LeaseWorker(leaser, "alpha"); err != nil {
+		t.Fatal(err)
+	}
+	var last int64
+	for i := 0; i < 100000; i++ {
+		id := w.Next()
+		if id <= last {
+			t.Fatal(`id <= last`)
+		}
+		last = id
+	}
+	p := w.Parse(last)
+	if p.Worker != 5 {
+		t.Fatalf("p.Worker should be 5. worker: %d", p.Worker)
+	}
+	if d := time.Since(p.Timestamp); d < -time.Millisecond || d > time.Second {
+		t.Fatalf("the timestamp is out of expectation. d: %v", d)
+	}
+
+	atomic.StoreInt64(&w.LeaseDeadline, 0)
+	func() {
+		defer func() {
+			_ = recover()
+		}()
+		w.Next()
+		t.Fatal("Next should have panicked after the worker lease expired")
+	}()
+
+	if err := w.LoadH28(1, nil); err == nil {
+		t.Fatal("LoadH28 should fail in the snowflake mode")
+	}
+	func() {
+		defer func() {
+			_ = recover()
+		}()
+		w.Reset(1 << 36)
+		t.Fatal("Reset should have panicked in the snowflake mode")
+	}()
+	func() {
+		defer func() {
+			_ = recover()
+		}()
+		NewWUID("alpha", nil, WithSnowflake(epoch, time.Minute), WithStep(16, 0))
+		t.Fatal("NewWUID should have panicked")
+	}()
+}
+
+func TestWithSnowflake_Concurrent(t *testing.T) {
+	w := NewWUID("alpha", nil, WithSnowflake(time.Now(), time.Minute))
+	defer w.Close()
+	if err := w.LeaseWorker(&fakeWorkerLeaser{}, "alpha"); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	m := make(map[int64]struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10000; j++ {
+				id := w.Next()
+				mu.Lock()
+				m[id] = struct{}{}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(m) != 100000 {
+		t.Fatal("duplication detected")
+	}
+}
+
+func TestWithSectionBits(t *testing.T) {
+	for bits := int8(1); bits <= 16; bits++ {
+		limit := int64(1)<<(27-bits) - 1
+		for _, section := range []int64{0, 1, 1<<bits - 1} {
+			w := NewWUID("alpha", nil, WithSectionBits(bits, section))
+			if err := w.VerifyH28(limit); err != nil {
+				t.Fatalf("VerifyH28 does not work as expected. bits: %d, n: %d, error: %s", bits, limit, err)
+			}
+			if err := w.VerifyH28(limit + 1); err == nil {
+				t.Fatalf("VerifyH28 does not work as expected. bits: %d, n: %d", bits, limit+1)
+			}
+
+			w.Reset(H28Mask)
+			if v := atomic.LoadInt64(&w.N); v>>(63-bits) != section || v>>36&limit != limit {
+				t.Fatalf("w.Section does not work as expected. w.N: %x, bits: %d, section: %d", v, bits, section)
+			}
+			if err := w.VerifyH28(limit); err == nil {
+				t.Fatalf("VerifyH28 does not work as expected. bits: %d, n: %d", bits, limit)
+			}
+
+			w.Reset(limit << 36)
+			p := w.Parse(w.Next())
+			if p.Section != section || p.H28 != limit || p.L36 != 1 {
+				t.Fatalf("Parse does not work as expected. bits: %d, section: %d, p: %+v", bits, section, p)
+			}
+		}
+	}
+
+	for _, args := range [][2]int64{{0, 0}, {17, 0}, {8, -1}, {8, 256}} {
+		func() {
+			defer func() {
+				_ = recover()
+			}()
+			NewWUID("alpha", nil, WithSectionBits(int8(args[0]), args[1]))
+			t.Fatalf("WithSectionBits should have panicked. bits: %d, section: %d", args[0], args[1])
+		}()
+	}
+}
+
+func TestWithModulo(t *testing.T) {
+	r := rand.New(rand.NewSource(time.Now().Unix()))
+	for _, n := range []int64{2, 3, 12, 1000, 1024} {
+		k := r.Int63n(n)
+		w := NewWUID("alpha", nil, WithModulo(n, k), WithSection(3))
+		w.Reset(r.Int63n(100)<<36 + r.Int63n(1000))
+		last := atomic.LoadInt64(&w.N)
+		for i := 0; i < 1000; i++ {
+			id := w.Next()
+			if id%n != k || w.ShardOf(id) != k || w.Parse(id).Shard != k {
+				t.Fatalf("id%%n != k. id: %d, n: %d, k: %d", id, n, k)
+			}
+			if id <= last || id>>60 != 3 {
+				t.Fatalf("something is wrong with Next(). id: %x, last: %x", id, last)
+			}
+			last = id
+		}
+	}
+
+	w := NewWUID("alpha", slog.NewScavenger(), WithModulo(12, 5))
+	w.Renew = func() error {
+		w.Reset(((atomic.LoadInt64(&w.N) >> 36) + 1) << 36)
+		return nil
+	}
+	w.Reset(1<<36 | Bye - 100)
+	for i := 0; i < 100; i++ {
+		w.Next()
+	}
+	waitUntilNumRenewedReaches(t, w, 1)
+	if id := w.Next(); id>>36 != 2 || id%12 != 5 {
+		t.Fatalf("the renew mechanism does not work as expected. id: %x", id)
+	}
+
+	for _, args := range [][2]int64{{1, 0}, {1025, 0}, {12, 12}, {12, -1}} {
+		func() {
+			defer func() {
+				_ = recover()
+			}()
+			NewWUID("alpha", nil, WithModulo(args[0], args[1]))
+			t.Fatalf("WithModulo should have panicked. n: %d, k: %d", args[0], args[1])
+		}()
+	}
+
+	func() {
+		defer func() {
+			_ = recover()
+		}()
+		NewWUID("alpha", nil, WithStep(4, 0), WithModulo(12, 5))
+		t.Fatal("NewWUID should have panicked")
+	}()
+
+	func() {
+		defer func() {
+			_ = recover()
+		}()
+		NewWUID("alpha", nil, WithModulo(12, 5), WithObfuscation(1))
+		t.Fatal("NewWUID should have panicked")
+	}()
+}
+
+func TestWUID_NextWithTag(t *testing.T) {
+	for _, opts := range [][]Option{
+		{WithStep(16, 0)},
+		{WithStep(16, 0), WithObfuscation(1)},
+		{WithStep(16, 0), WithTimestamp(time.Now(), time.Hour)},
+	} {
+		w := NewWUID("alpha", nil, opts...)
+		w.Reset(5<<36 + 3)
+		m := make(map[int64]struct{})
+		for i := int64(0); i < 1000; i++ {
+			tag := i % 16
+			id := w.NextWithTag(tag)
+			if p := w.Parse(id); p.Tag != tag || p.H28 != 5 {
+				t.Fatalf("Parse does not work as expected. tag: %d, p: %+v", tag, p)
+			}
+			m[id] = struct{}{}
+		}
+		if len(m) != 1000 {
+			t.Fatal("duplication detected")
+		}
+
+		func() {
+			defer func() {
+				_ = recover()
+			}()
+			w.NextWithTag(16)
+			t.Fatal("NextWithTag should have panicked")
+		}()
+	}
+
+	func() {
+		defer func() {
+			_ = recover()
+		}()
+		NewWUID("alpha", nil, WithStep(16, 10)).NextWithTag(1)
+		t.Fatal("NextWithTag should have panicked")
+	}()
+}
+
+func TestNew_OptionError(t *testing.T) {
+	_, err := New("alpha", nil, WithSection(8), WithStep(3, 0), WithObfuscation(0), WithModulo(1, 0))
+	oe, ok := err.(*OptionError)
+	if !ok {
+		t.Fatalf("New should fail with an OptionError. err: %v", err)
+	}
+	if len(oe.Errors) != 4 {
+		t.Fatalf("all the invalid options should be reported. err: %v", err)
+	}
+	if !strings.Contains(err.Error(), "WithSection") || !strings.Contains(err.Error(), "WithObfuscation") {
+		t.Fatalf("the error is not descriptive. err: %v", err)
+	}
+
+	if _, err := New("alpha", nil, WithStep(4, 0), WithStep(8, 0)); err == nil {
+		t.Fatal("New should fail with a second WithStep")
+	}
+	if _, err := New("alpha", nil, WithSnowflake(time.Now(), time.Minute), WithSection(1)); err == nil {
+		t.Fatal("New should fail with conflicting options")
+	}
+	if _, err := New("alpha", nil, WithStep(4, 0)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithRenewTimeout(t *testing.T) {
+	var h28 int64
+	var timeouts []time.Duration
+	src := H28SourceFunc(func(ctx context.Context) (int64, error) {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			timeouts = append(timeouts, time.Until(deadline))
+		}
+		return atomic.AddInt64(&h28, 1), nil
+	})
+
+	w := NewWUID("alpha", nil, WithRenewTimeout(time.Minute))
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	cancel1()
+	if err := w.LoadH28FromSourceContext(ctx1, src); err == nil {
+		t.Fatal("LoadH28FromSourceContext should fail when ctx is canceled")
+	}
+	if err := w.LoadH28FromSourceContext(context.Background(), src); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.RenewNow(); err != nil {
+		t.Fatal(err)
+	}
+	if len(timeouts) != 1 || timeouts[0] <= time.Second*50 || timeouts[0] > time.Minute {
+		t.Fatalf("the renew timeout does not work as expected. timeouts: %v", timeouts)
+	}
+
+	if _, err := New("alpha", nil, WithRenewTimeout(0)); err == nil {
+		t.Fatal("New should fail when the timeout is not positive")
+	}
+}
+
+func TestMaxRenewTimeout(t *testing.T) {
+	unwrap := func(w *WUID) *WUID { return w }
+	if d := MaxRenewTimeout(nil, unwrap); d != DefaultRenewTimeout {
+		t.Fatalf("MaxRenewTimeout should return DefaultRenewTimeout when ws is empty. d: %v", d)
+	}
+	ws := []*WUID{
+		NewWUID("alpha", nil, WithRenewTimeout(time.Second)),
+		NewWUID("beta", nil, WithRenewTimeout(time.Minute)),
+		NewWUID("gamma", nil, WithRenewTimeout(time.Second*2)),
+	}
+	if d := MaxRenewTimeout(ws, unwrap); d != time.Minute {
+		t.Fatalf("MaxRenewTimeout should return the longest RenewTimeout. d: %v", d)
+	}
+}
@@ -0,0 +1,149 @@
+package wuid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/edwingeng/wuid/internal"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"time"
+)
+
+// LoadH28FromMongoInBatch is like LoadH28FromMongo, but loads h28 for all of ws in one transaction.
+// docIDs[i] is the document ID of ws[i]. Transactions are only available on a replica set or a
+// sharded cluster. The generators that fail are left untouched, and the first error is returned.
+// Each generator resumes a leased block or falls back to its reserve just like LoadH28FromMongo.
+// The round trip is bounded by the longest RenewTimeout of ws.
+func LoadH28FromMongoInBatch(newClient NewClient, dbName, coll string, ws []*WUID, docIDs []string) error {
+	ctx1, cancel1 := context.WithTimeout(context.Background(), internal.MaxRenewTimeout(ws, unwrap))
+	defer cancel1()
+	return LoadH28FromMongoInBatchContext(ctx1, newClient, dbName, coll, ws, docIDs)
+}
+
+// LoadH28FromMongoInBatchContext is like LoadH28FromMongoInBatch, but the round trip is bounded by ctx.
+func LoadH28FromMongoInBatchContext(ctx context.Context, newClient NewClient, dbName, coll string, ws []*WUID, docIDs []string) error {
+	if len(dbName) == 0 {
+		return errors.New("dbName cannot be empty")
+	}
+	if len(coll) == 0 {
+		return errors.New("coll cannot be empty")
+	}
+	if len(ws) != len(docIDs) {
+		return errors.New("ws and docIDs should have the same length")
+	}
+	seen := make(map[string]struct{}, len(docIDs))
+	for _, docID := range docIDs {
+		if len(docID) == 0 {
+			return errors.New("docID cannot be empty")
+		}
+		if _, ok := seen[docID]; ok {
+			return fmt.Errorf("duplicate docID: %s", docID)
+		}
+		seen[docID] = struct{}{}
+	}
+	if len(ws) == 0 {
+		return nil
+	}
+
+	h28s, err := incInBatch(ctx, newClient, dbName, coll, docIDs)
+	var firstErr error
+	for i, w := range ws {
+		w, docID := w, docIDs[i]
+		var h28 int64
+		acqErr := err
+		if acqErr == nil {
+			var ok bool
+			if h28, ok = h28s[docID]; !ok {
+				acqErr = errors.New("the document is missing")
+			}
+		}
+		if err := w.w.LoadAcquiredH28(ctx, NewSource(newClient, dbName, coll, docID), h28, acqErr); err != nil {
+			w.w.Warnf("<wuid> failed to load h28. name: %s, docID: %s, reason: %+v", w.w.Name, docID, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to load h28. name: %s, docID: %s, reason: %w", w.w.Name, docID, err)
+			}
+		}
+	}
+	return firstErr
+}
+
+// incInBatch increments the documents of docIDs in one transaction.
+func incInBatch(ctx context.Context, newClient NewClient, dbName, coll string, docIDs []string) (map[string]int64, error) {
+	client, autoDisconnect, err := newClient()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if autoDisconnect {
+			ctx2, cancel2 := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel2()
+			_ = client.Disconnect(ctx2)
+		}
+	}()
+
+	if err := client.Ping(ctx, readpref.Primary()); err != nil {
+		return nil, err
+	}
+
+	session, err := client.StartSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+
+	txnOpts := options.Transaction().
+		SetReadConcern(readconcern.Snapshot()).
+		SetWriteConcern(writeconcern.New(writeconcern.WMajority())).
+		SetReadPreference(readpref.Primary())
+
+	h28s := make(map[string]int64, len(docIDs))
+	c := client.Database(dbName).Collection(coll)
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		models := make([]mongo.WriteModel, len(docIDs))
+		for i, docID := range docIDs {
+			update := bson.D{
+				{
+					Key: "$inc",
+					Value: bson.D{
+						{Key: "n", Value: int32(1)},
+					},
+				},
+			}
+			models[i] = mongo.NewUpdateOneModel().
+				SetFilter(bson.D{{Key: "_id", Value: docID}}).
+				SetUpdate(update).
+				SetUpsert(true)
+		}
+		if _, err := c.BulkWrite(sc, models); err != nil {
+			return nil, err
+		}
+
+		filter := bson.D{
+			{Key: "_id", Value: bson.D{{Key: "$in", Value: docIDs}}},
+		}
+		cursor, err := c.Find(sc, filter)
+		if err != nil {
+			return nil, err
+		}
+		var docs []struct {
+			ID string `bson:"_id"`
+			N  int32
+		}
+		if err := cursor.All(sc, &docs); err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			h28s[doc.ID] = int64(doc.N)
+		}
+		return nil, nil
+	}, txnOpts)
+	if err != nil {
+		return nil, err
+	}
+	return h28s, nil
+}
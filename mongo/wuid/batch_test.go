@@ -0,0 +1,37 @@
+package wuid
+
+import (
+	"go.mongodb.org/mongo-driver/mongo"
+	"testing"
+)
+
+func TestLoadH28FromMongoInBatch(t *testing.T) {
+	client, err := connectMongodb()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newClient := func() (*mongo.Client, bool, error) {
+		return client, false, nil
+	}
+
+	ws := []*WUID{NewWUID("alpha", dumb), NewWUID("beta", dumb)}
+	docIDs := []string{"batch-alpha", "batch-beta"}
+	if err := LoadH28FromMongoInBatch(newClient, cfg.dbName, cfg.coll, ws, docIDs); err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range ws {
+		if w.Parse(w.Next()).H28 <= 0 {
+			t.Fatalf("%s is not loaded", w.w.Name)
+		}
+		if w.w.Renew == nil {
+			t.Fatal(`w.w.Renew == nil`)
+		}
+	}
+
+	if err := LoadH28FromMongoInBatch(newClient, cfg.dbName, cfg.coll, ws, []string{"batch-alpha", "batch-alpha"}); err == nil {
+		t.Fatal("LoadH28FromMongoInBatch should reject duplicate docIDs")
+	}
+	if err := LoadH28FromMongoInBatch(newClient, cfg.dbName, cfg.coll, ws, docIDs[:1]); err == nil {
+		t.Fatal("LoadH28FromMongoInBatch should fail when the lengths differ")
+	}
+}
@@ -0,0 +1,169 @@
+package wuid
+
+import (
+	"context"
+	"errors"
+	"github.com/edwingeng/wuid/internal"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"time"
+)
+
+type blockLeaser struct {
+	newClient NewClient
+	dbName    string
+	coll      string
+}
+
+// openCollection returns the collection with the majority read and write concerns, and a function
+// that disconnects the client if necessary.
+func openCollection(newClient NewClient, dbName, coll string) (*mongo.Collection, func(), error) {
+	client, autoDisconnect, err := newClient()
+	if err != nil {
+		return nil, nil, err
+	}
+	done := func() {
+		if autoDisconnect {
+			ctx2, cancel2 := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel2()
+			_ = client.Disconnect(ctx2)
+		}
+	}
+
+	collOpts := &options.CollectionOptions{
+		ReadConcern:    readconcern.Majority(),
+		WriteConcern:   writeconcern.New(writeconcern.WMajority()),
+		ReadPreference: readpref.Primary(),
+	}
+	return client.Database(dbName).Collection(coll, collOpts), done, nil
+}
+
+// leaseAvailable returns an aggregation expression that is true if the lease of a document is held by
+// owner or has expired by the clock of the server. op is either $lt or $lte.
+func leaseAvailable(owner, op string) bson.D {
+	return bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "$eq", Value: bson.A{"$owner", bson.D{{Key: "$literal", Value: owner}}}}},
+		bson.D{{Key: op, Value: bson.A{"$expiresAt", "$$NOW"}}},
+	}}}
+}
+
+// expiresAt returns an aggregation expression of the time ttl after the clock of the server.
+func expiresAt(ttl time.Duration) bson.D {
+	return bson.D{{Key: "$add", Value: bson.A{"$$NOW", ttl.Milliseconds()}}}
+}
+
+func (l *blockLeaser) ClaimBlock(ctx context.Context, owner string, ttl time.Duration, below int64) (bool, int64, int64, int64, error) {
+	c, done, err := openCollection(l.newClient, l.dbName, l.coll)
+	if err != nil {
+		return false, 0, 0, 0, err
+	}
+	defer done()
+
+	var doc struct {
+		H28        int64 `bson:"_id"`
+		Checkpoint int64
+		Token      int64
+	}
+
+	filter := bson.D{
+		{Key: "$expr", Value: leaseAvailable(owner, "$lte")},
+		{Key: "checkpoint", Value: bson.D{{Key: "$lt", Value: below}}},
+	}
+	update := mongo.Pipeline{
+		{{
+			Key: "$set",
+			Value: bson.D{
+				{Key: "owner", Value: bson.D{{Key: "$literal", Value: owner}}},
+				{Key: "expiresAt", Value: expiresAt(ttl)},
+				{Key: "token", Value: bson.D{{Key: "$add", Value: bson.A{"$token", int64(1)}}}},
+			},
+		}},
+	}
+
+	var findOneAndUpdateOptions options.FindOneAndUpdateOptions
+	findOneAndUpdateOptions.SetSort(bson.D{{Key: "_id", Value: 1}}).SetReturnDocument(options.After)
+	err = c.FindOneAndUpdate(ctx, filter, update, &findOneAndUpdateOptions).Decode(&doc)
+	switch {
+	case err == mongo.ErrNoDocuments:
+		return false, 0, 0, 0, nil
+	case err != nil:
+		return false, 0, 0, 0, err
+	}
+	return true, doc.H28, doc.Checkpoint, doc.Token, nil
+}
+
+func (l *blockLeaser) SaveBlock(ctx context.Context, owner string, h28, token, checkpoint int64, ttl time.Duration) (bool, error) {
+	c, done, err := openCollection(l.newClient, l.dbName, l.coll)
+	if err != nil {
+		return false, err
+	}
+	defer done()
+
+	filter := bson.D{
+		{Key: "_id", Value: h28},
+		{Key: "token", Value: token},
+	}
+	update := mongo.Pipeline{
+		{{
+			Key: "$set",
+			Value: bson.D{
+				{Key: "owner", Value: bson.D{{Key: "$literal", Value: owner}}},
+				{Key: "checkpoint", Value: checkpoint},
+				{Key: "expiresAt", Value: expiresAt(ttl)},
+			},
+		}},
+	}
+
+	var updateOptions options.UpdateOptions
+	updateOptions.SetUpsert(token == 1)
+	r, err := c.UpdateOne(ctx, filter, update, &updateOptions)
+	switch {
+	case mongo.IsDuplicateKeyError(err):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return r.MatchedCount+r.UpsertedCount > 0, nil
+}
+
+func (l *blockLeaser) DropBlock(ctx context.Context, h28, token int64) error {
+	c, done, err := openCollection(l.newClient, l.dbName, l.coll)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	filter := bson.D{
+		{Key: "_id", Value: h28},
+		{Key: "token", Value: token},
+	}
+	_, err = c.DeleteOne(ctx, filter)
+	return err
+}
+
+// NewBlockLeaser returns a BlockLeaser that keeps the blocks in a MongoDB collection.
+func NewBlockLeaser(newClient NewClient, dbName, coll string) BlockLeaser {
+	return &blockLeaser{newClient: newClient, dbName: dbName, coll: coll}
+}
+
+type BlockLeaser = internal.BlockLeaser
+
+// LeaseBlocksFromMongo makes w record in MongoDB the checkpoint of every h28 block it loads, on behalf of
+// owner, which should survive restarts, e.g. a pod name. When h28 is loaded for the first time, w resumes
+// the unused tail of a block whose lease has expired or is held by owner, instead of acquiring a new one.
+// Next never passes the recorded checkpoint, so two holders of a block can never overlap. Close records
+// the exact position and releases the lease. The blocks must be leased before h28 is loaded, and each
+// document of h28 needs its own collection of blocks. The version of MongoDB must be 4.2 or higher.
+func (w *WUID) LeaseBlocksFromMongo(newClient NewClient, dbName, coll, owner string, ttl time.Duration) error {
+	if len(dbName) == 0 {
+		return errors.New("dbName cannot be empty")
+	}
+	if len(coll) == 0 {
+		return errors.New("coll cannot be empty")
+	}
+	return w.w.LeaseBlocks(NewBlockLeaser(newClient, dbName, coll), owner, ttl)
+}
@@ -0,0 +1,52 @@
+package wuid
+
+import (
+	"fmt"
+	"go.mongodb.org/mongo-driver/mongo"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestWUID_LeaseBlocksFromMongo(t *testing.T) {
+	newClient := func() (*mongo.Client, bool, error) {
+		client, err := connectMongodb()
+		return client, true, err
+	}
+
+	coll := fmt.Sprintf("wuid_block_%d", rand.Int63())
+	w1 := NewWUID("alpha", dumb)
+	if err := w1.LeaseBlocksFromMongo(newClient, cfg.dbName, coll, "pod-1", time.Second*10); err != nil {
+		t.Fatal(err)
+	}
+	if err := w1.LoadH28FromMongo(newClient, cfg.dbName, cfg.coll, cfg.docID); err != nil {
+		t.Fatal(err)
+	}
+	w1.Next()
+	last := w1.Next()
+	w1.Close()
+
+	w2 := NewWUID("alpha", dumb)
+	defer w2.Close()
+	if err := w2.LeaseBlocksFromMongo(newClient, cfg.dbName, coll, "pod-2", time.Second*10); err != nil {
+		t.Fatal(err)
+	}
+	if err := w2.LoadH28FromMongo(newClient, cfg.dbName, cfg.coll, cfg.docID); err != nil {
+		t.Fatal(err)
+	}
+	if v := w2.Next(); v != last+1 {
+		t.Fatalf("the block is not resumed. v: %d, last: %d", v, last)
+	}
+
+	w3 := NewWUID("alpha", dumb)
+	defer w3.Close()
+	if err := w3.LeaseBlocksFromMongo(newClient, cfg.dbName, coll, "pod-3", time.Second*10); err != nil {
+		t.Fatal(err)
+	}
+	if err := w3.LoadH28FromMongo(newClient, cfg.dbName, cfg.coll, cfg.docID); err != nil {
+		t.Fatal(err)
+	}
+	if w3.Parse(w3.Next()).H28 == w2.Parse(w2.Next()).H28 {
+		t.Fatal("a leased block should never be shared")
+	}
+}
@@ -0,0 +1,154 @@
+package wuid
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type controlChannel struct {
+	newClient NewClient
+	dbName    string
+	coll      string
+	name      string
+}
+
+// NewControlChannel returns a ControlChannel that keeps the state of name in the MongoDB document
+// whose _id is name, and the acknowledgements in the documents whose _id is name+"/"+owner. The
+// watchers are notified through a change stream, which requires a replica set or a sharded cluster.
+func NewControlChannel(newClient NewClient, dbName, coll, name string) ControlChannel {
+	return &controlChannel{newClient: newClient, dbName: dbName, coll: coll, name: name}
+}
+
+func (c *controlChannel) Send(ctx context.Context, cmd Command) error {
+	coll, done, err := openCollection(c.newClient, c.dbName, c.coll)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	var update bson.D
+	switch cmd {
+	case CommandRenew:
+		update = bson.D{{Key: "$inc", Value: bson.D{{Key: "renewSeq", Value: int64(1)}}}}
+	case CommandPause:
+		update = bson.D{{Key: "$set", Value: bson.D{{Key: "paused", Value: true}}}}
+	case CommandResume:
+		update = bson.D{{Key: "$set", Value: bson.D{{Key: "paused", Value: false}}}}
+	default:
+		return errors.New("unknown command")
+	}
+
+	var updateOptions options.UpdateOptions
+	updateOptions.SetUpsert(true)
+	_, err = coll.UpdateOne(ctx, bson.D{{Key: "_id", Value: c.name}}, update, &updateOptions)
+	return err
+}
+
+func (c *controlChannel) State(ctx context.Context) (ControlState, error) {
+	coll, done, err := openCollection(c.newClient, c.dbName, c.coll)
+	if err != nil {
+		return ControlState{}, err
+	}
+	defer done()
+
+	var doc struct {
+		RenewSeq int64 `bson:"renewSeq"`
+		Paused   bool  `bson:"paused"`
+	}
+	err = coll.FindOne(ctx, bson.D{{Key: "_id", Value: c.name}}).Decode(&doc)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return ControlState{}, err
+	}
+	return ControlState{RenewSeq: doc.RenewSeq, Paused: doc.Paused}, nil
+}
+
+func (c *controlChannel) Wait(ctx context.Context) error {
+	coll, done, err := openCollection(c.newClient, c.dbName, c.coll)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.D{{Key: "documentKey._id", Value: c.name}}}},
+	}
+	stream, err := coll.Watch(ctx, pipeline)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+	defer func() {
+		ctx2, cancel2 := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel2()
+		_ = stream.Close(ctx2)
+	}()
+
+	if !stream.Next(ctx) && ctx.Err() == nil {
+		return stream.Err()
+	}
+	return nil
+}
+
+func (c *controlChannel) Ack(ctx context.Context, ack ControlAck) error {
+	coll, done, err := openCollection(c.newClient, c.dbName, c.coll)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	filter := bson.D{
+		{Key: "_id", Value: c.name + "/" + ack.Owner},
+	}
+	update := bson.D{
+		{
+			Key: "$set",
+			Value: bson.D{
+				{Key: "name", Value: c.name},
+				{Key: "owner", Value: ack.Owner},
+				{Key: "renewSeq", Value: ack.RenewSeq},
+				{Key: "paused", Value: ack.Paused},
+				{Key: "err", Value: ack.Err},
+				{Key: "time", Value: ack.Time},
+			},
+		},
+	}
+
+	var updateOptions options.UpdateOptions
+	updateOptions.SetUpsert(true)
+	_, err = coll.UpdateOne(ctx, filter, update, &updateOptions)
+	return err
+}
+
+func (c *controlChannel) Acks(ctx context.Context) ([]ControlAck, error) {
+	coll, done, err := openCollection(c.newClient, c.dbName, c.coll)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	cursor, err := coll.Find(ctx, bson.D{{Key: "name", Value: c.name}})
+	if err != nil {
+		return nil, err
+	}
+	var docs []struct {
+		Owner    string    `bson:"owner"`
+		RenewSeq int64     `bson:"renewSeq"`
+		Paused   bool      `bson:"paused"`
+		Err      string    `bson:"err"`
+		Time     time.Time `bson:"time"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	acks := make([]ControlAck, len(docs))
+	for i, doc := range docs {
+		acks[i] = ControlAck(doc)
+	}
+	return acks, nil
+}
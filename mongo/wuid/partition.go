@@ -0,0 +1,113 @@
+package wuid
+
+import (
+	"context"
+	"errors"
+	"github.com/edwingeng/wuid/internal"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"time"
+)
+
+type partitionSource struct {
+	newClient NewClient
+	dbName    string
+	coll      string
+	docID     string
+	n         int64
+	i         int64
+}
+
+// NewPartitionSource returns an H28Source that only hands out numbers congruent to i modulo n,
+// i.e. the i-th partition of n. Each partition should be kept by an independent MongoDB, whose
+// version must be 4.2 or higher. n must be in between [1, 1024], and i must be in between [0, n).
+func NewPartitionSource(newClient NewClient, dbName, coll, docID string, n, i int64) H28Source {
+	internal.CheckPartition(n, i)
+	return &partitionSource{newClient: newClient, dbName: dbName, coll: coll, docID: docID, n: n, i: i}
+}
+
+func (s *partitionSource) Acquire(ctx context.Context) (int64, error) {
+	if len(s.dbName) == 0 {
+		return 0, errors.New("dbName cannot be empty")
+	}
+	if len(s.coll) == 0 {
+		return 0, errors.New("coll cannot be empty")
+	}
+	if len(s.docID) == 0 {
+		return 0, errors.New("docID cannot be empty")
+	}
+
+	client, autoDisconnect, err := s.newClient()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if autoDisconnect {
+			ctx2, cancel2 := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel2()
+			_ = client.Disconnect(ctx2)
+		}
+	}()
+
+	collOpts := &options.CollectionOptions{
+		ReadConcern:    readconcern.Majority(),
+		WriteConcern:   writeconcern.New(writeconcern.WMajority()),
+		ReadPreference: readpref.Primary(),
+	}
+
+	var doc struct {
+		N int32
+	}
+
+	n, i := int32(s.n), int32(s.i)
+	filter := bson.D{
+		{Key: "_id", Value: s.docID},
+	}
+	// n = c + (i - c - 1) mod n + 1, where c is the current value.
+	mod := func(x interface{}) bson.D {
+		return bson.D{{Key: "$mod", Value: bson.A{x, n}}}
+	}
+	next := bson.D{
+		{
+			Key: "$let",
+			Value: bson.D{
+				{Key: "vars", Value: bson.D{{Key: "c", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$n", int32(0)}}}}}},
+				{Key: "in", Value: bson.D{{Key: "$add", Value: bson.A{
+					"$$c",
+					mod(bson.D{{Key: "$add", Value: bson.A{mod(bson.D{{Key: "$subtract", Value: bson.A{i - 1, "$$c"}}}), n}}}),
+					int32(1),
+				}}}},
+			},
+		},
+	}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.D{{Key: "n", Value: next}}}},
+	}
+
+	var findOneAndUpdateOptions options.FindOneAndUpdateOptions
+	findOneAndUpdateOptions.SetUpsert(true).SetReturnDocument(options.After)
+	c := client.Database(s.dbName).Collection(s.coll, collOpts)
+	err = c.FindOneAndUpdate(ctx, filter, update, &findOneAndUpdateOptions).Decode(&doc)
+	if err != nil {
+		return 0, err
+	}
+	return int64(doc.N), nil
+}
+
+// Partition returns n and i, so that the generators verify the residue of each h28.
+func (s *partitionSource) Partition() (n, i int64) {
+	return s.n, s.i
+}
+
+// Check is the same as the Check of the source returned by NewSource.
+func (s *partitionSource) Check(ctx context.Context) (int64, error) {
+	return (&source{newClient: s.newClient, dbName: s.dbName, coll: s.coll, docID: s.docID}).Check(ctx)
+}
+
+func (s *partitionSource) String() string {
+	return "mongo:" + s.dbName + "." + s.coll + "/" + s.docID
+}
@@ -0,0 +1,30 @@
+package wuid
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/mongo"
+	"testing"
+)
+
+func TestNewPartitionSource(t *testing.T) {
+	client, err := connectMongodb()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newClient := func() (*mongo.Client, bool, error) {
+		return client, false, nil
+	}
+
+	src := NewPartitionSource(newClient, cfg.dbName, cfg.coll, cfg.docID+"-partition", 3, 2)
+	var last int64
+	for i := 0; i < 3; i++ {
+		h28, err := src.Acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h28%3 != 2 || h28 <= last {
+			t.Fatalf("h28 is not in the partition. h28: %d, last: %d", h28, last)
+		}
+		last = h28
+	}
+}
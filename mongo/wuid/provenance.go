@@ -0,0 +1,81 @@
+package wuid
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sort"
+)
+
+type provenanceStore struct {
+	newClient NewClient
+	dbName    string
+	coll      string
+}
+
+// NewProvenanceStore returns a ProvenanceStore that keeps the records of each h28 as subdocuments
+// of a MongoDB document whose _id is h28.
+func NewProvenanceStore(newClient NewClient, dbName, coll string) ProvenanceStore {
+	return &provenanceStore{newClient: newClient, dbName: dbName, coll: coll}
+}
+
+func (s *provenanceStore) Record(ctx context.Context, h28 int64, rec ProvenanceRecord) error {
+	c, done, err := openCollection(s.newClient, s.dbName, s.coll)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	filter := bson.D{
+		{Key: "_id", Value: h28},
+	}
+	update := bson.D{
+		{
+			Key: "$push",
+			Value: bson.D{
+				{
+					Key: "records",
+					Value: bson.D{
+						{Key: "name", Value: rec.Name},
+						{Key: "hostname", Value: rec.Hostname},
+						{Key: "pid", Value: rec.PID},
+						{Key: "version", Value: rec.Version},
+						{Key: "time", Value: rec.Time},
+						{Key: "start", Value: rec.Start},
+					},
+				},
+			},
+		},
+	}
+
+	var updateOptions options.UpdateOptions
+	updateOptions.SetUpsert(true)
+	_, err = c.UpdateOne(ctx, filter, update, &updateOptions)
+	return err
+}
+
+func (s *provenanceStore) Lookup(ctx context.Context, h28 int64) ([]ProvenanceRecord, error) {
+	c, done, err := openCollection(s.newClient, s.dbName, s.coll)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	var doc struct {
+		Records []ProvenanceRecord
+	}
+	err = c.FindOne(ctx, bson.D{{Key: "_id", Value: h28}}).Decode(&doc)
+	switch {
+	case err == mongo.ErrNoDocuments:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	records := doc.Records
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Start < records[j].Start
+	})
+	return records, nil
+}
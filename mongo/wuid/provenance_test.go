@@ -0,0 +1,29 @@
+package wuid
+
+import (
+	"fmt"
+	"go.mongodb.org/mongo-driver/mongo"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func TestWithProvenance(t *testing.T) {
+	newClient := func() (*mongo.Client, bool, error) {
+		client, err := connectMongodb()
+		return client, true, err
+	}
+
+	store := NewProvenanceStore(newClient, cfg.dbName, fmt.Sprintf("wuid_provenance_%d", rand.Int63()))
+	w := NewWUID("alpha", dumb, WithProvenance(store, "v1.2.3"))
+	if err := w.LoadH28FromMongo(newClient, cfg.dbName, cfg.coll, cfg.docID); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := w.Provenance(w.Next())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Name != "alpha" || rec.PID != os.Getpid() || rec.Version != "v1.2.3" {
+		t.Fatalf("unexpected record: %+v", rec)
+	}
+}
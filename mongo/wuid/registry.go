@@ -0,0 +1,65 @@
+package wuid
+
+import (
+	"fmt"
+	"github.com/edwingeng/slog"
+	"github.com/edwingeng/wuid/internal"
+)
+
+// Registry creates generators by name on demand. All the generators share the same MongoDB configuration,
+// and the document ID of each generator is made by docIDTemplate, e.g. "wuid-%s".
+type Registry struct {
+	r internal.Registry[*WUID]
+}
+
+// NewRegistry creates a new Registry. opts are applied to each generator.
+func NewRegistry(newClient NewClient, dbName, coll, docIDTemplate string, logger slog.Logger, opts ...Option) *Registry {
+	reg := &Registry{}
+	reg.r.New = func(name string) (*WUID, error) {
+		w := NewWUID(name, logger, opts...)
+		if err := w.LoadH28FromMongo(newClient, dbName, coll, fmt.Sprintf(docIDTemplate, name)); err != nil {
+			return nil, err
+		}
+		return w, nil
+	}
+	reg.r.NewInBatch = func(names []string) ([]*WUID, error) {
+		ws := make([]*WUID, len(names))
+		docIDs := make([]string, len(names))
+		for i, name := range names {
+			ws[i] = NewWUID(name, logger, opts...)
+			docIDs[i] = fmt.Sprintf(docIDTemplate, name)
+		}
+		if err := LoadH28FromMongoInBatch(newClient, dbName, coll, ws, docIDs); err != nil {
+			for _, w := range ws {
+				w.Close()
+			}
+			return nil, err
+		}
+		return ws, nil
+	}
+	reg.r.Unwrap = unwrap
+	return reg
+}
+
+// Get returns the generator named name. The generator is created and loaded on the first call.
+func (r *Registry) Get(name string) (*WUID, error) {
+	return r.r.Get(name)
+}
+
+// Preload creates and loads the generators of names in one transaction, so that the following Get
+// calls on them return immediately. It requires a replica set or a sharded cluster.
+func (r *Registry) Preload(names ...string) error {
+	return r.r.Preload(names...)
+}
+
+type RegistryStats = internal.RegistryStats
+
+// Stats returns the aggregate statistics of all the generators.
+func (r *Registry) Stats() RegistryStats {
+	return r.r.Stats()
+}
+
+// Close closes all the generators. Get fails after Close.
+func (r *Registry) Close() {
+	r.r.Close()
+}
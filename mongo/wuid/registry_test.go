@@ -0,0 +1,34 @@
+package wuid
+
+import (
+	"go.mongodb.org/mongo-driver/mongo"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	newClient := func() (*mongo.Client, bool, error) {
+		client, err := connectMongodb()
+		return client, true, err
+	}
+
+	r := NewRegistry(newClient, cfg.dbName, cfg.coll, "wuid-%s", dumb)
+	w1, err := r.Get("alpha")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w2, err := r.Get("beta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w3, _ := r.Get("alpha"); w3 != w1 || w2 == w1 {
+		t.Fatal("Get does not work as expected")
+	}
+	if stats := r.Stats(); stats.NumGenerators != 2 {
+		t.Fatalf("Stats does not work as expected. stats: %+v", stats)
+	}
+
+	r.Close()
+	if _, err := r.Get("gamma"); err == nil {
+		t.Fatal("Get should fail after Close")
+	}
+}
@@ -0,0 +1,96 @@
+package wuid
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"time"
+)
+
+type sectionLeaser struct {
+	newClient NewClient
+	dbName    string
+	coll      string
+}
+
+func (l *sectionLeaser) LeaseSection(ctx context.Context, section int64, owner string, ttl time.Duration, h28 int64, claim bool) (bool, int64, error) {
+	client, autoDisconnect, err := l.newClient()
+	if err != nil {
+		return false, 0, err
+	}
+	defer func() {
+		if autoDisconnect {
+			ctx2, cancel2 := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel2()
+			_ = client.Disconnect(ctx2)
+		}
+	}()
+
+	collOpts := &options.CollectionOptions{
+		ReadConcern:    readconcern.Majority(),
+		WriteConcern:   writeconcern.New(writeconcern.WMajority()),
+		ReadPreference: readpref.Primary(),
+	}
+
+	var doc struct {
+		H28 int64
+	}
+
+	// The expiry is measured by the clock of the server, so that the skew of the clients does not matter.
+	filter := bson.D{
+		{Key: "_id", Value: section},
+		{Key: "$expr", Value: leaseAvailable(owner, "$lt")},
+	}
+	if !claim {
+		filter = bson.D{
+			{Key: "_id", Value: section},
+			{Key: "owner", Value: owner},
+			{Key: "$expr", Value: bson.D{{Key: "$gt", Value: bson.A{"$expiresAt", "$$NOW"}}}},
+		}
+	}
+	update := mongo.Pipeline{
+		{{
+			Key: "$set",
+			Value: bson.D{
+				{Key: "owner", Value: bson.D{{Key: "$literal", Value: owner}}},
+				{Key: "expiresAt", Value: expiresAt(ttl)},
+				{Key: "h28", Value: bson.D{{Key: "$max", Value: bson.A{"$h28", h28}}}},
+			},
+		}},
+	}
+
+	var findOneAndUpdateOptions options.FindOneAndUpdateOptions
+	findOneAndUpdateOptions.SetUpsert(claim).SetReturnDocument(options.Before)
+	c := client.Database(l.dbName).Collection(l.coll, collOpts)
+	err = c.FindOneAndUpdate(ctx, filter, update, &findOneAndUpdateOptions).Decode(&doc)
+	switch {
+	case err == mongo.ErrNoDocuments && !claim:
+		return false, 0, nil
+	case err == mongo.ErrNoDocuments:
+		return true, 0, nil
+	case mongo.IsDuplicateKeyError(err):
+		return false, 0, nil
+	case err != nil:
+		return false, 0, err
+	}
+	return true, doc.H28, nil
+}
+
+// LeaseSectionFromMongo joins the section held by owner, which usually identifies a cluster, or claims a free one,
+// and keeps renewing the lease in the background until w is closed. Next panics if the lease is lost.
+// The width of the section field is specified by WithSectionBits, and defaults to 3 bits.
+// The section must be leased before h28 is loaded. The version of MongoDB must be 4.2 or higher.
+func (w *WUID) LeaseSectionFromMongo(newClient NewClient, dbName, coll, owner string, ttl time.Duration) error {
+	if len(dbName) == 0 {
+		return errors.New("dbName cannot be empty")
+	}
+	if len(coll) == 0 {
+		return errors.New("coll cannot be empty")
+	}
+	return w.w.LeaseSection(&sectionLeaser{newClient: newClient, dbName: dbName, coll: coll}, owner, ttl)
+}
@@ -0,0 +1,42 @@
+package wuid
+
+import (
+	"fmt"
+	"go.mongodb.org/mongo-driver/mongo"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestWUID_LeaseSectionFromMongo(t *testing.T) {
+	newClient := func() (*mongo.Client, bool, error) {
+		client, err := connectMongodb()
+		return client, true, err
+	}
+
+	coll := fmt.Sprintf("wuid_section_%d", rand.Int63())
+	w1 := NewWUID("alpha", dumb, WithSectionBits(1, 0))
+	defer w1.Close()
+	if err := w1.LeaseSectionFromMongo(newClient, cfg.dbName, coll, "alpha", time.Second*10); err != nil {
+		t.Fatal(err)
+	}
+	w2 := NewWUID("beta", dumb, WithSectionBits(1, 0))
+	defer w2.Close()
+	if err := w2.LeaseSectionFromMongo(newClient, cfg.dbName, coll, "beta", time.Second*10); err != nil {
+		t.Fatal(err)
+	}
+	w3 := NewWUID("gamma", dumb, WithSectionBits(1, 0))
+	if err := w3.LeaseSectionFromMongo(newClient, cfg.dbName, coll, "gamma", time.Second*10); err == nil {
+		t.Fatal("LeaseSectionFromMongo should fail when there is no free section")
+	}
+
+	if err := w1.LoadH28FromMongo(newClient, cfg.dbName, cfg.coll, cfg.docID); err != nil {
+		t.Fatal(err)
+	}
+	if err := w2.LoadH28FromMongo(newClient, cfg.dbName, cfg.coll, cfg.docID); err != nil {
+		t.Fatal(err)
+	}
+	if w1.Parse(w1.Next()).Section == w2.Parse(w2.Next()).Section {
+		t.Fatal("two owners should never share a section")
+	}
+}
@@ -0,0 +1,142 @@
+package wuid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/edwingeng/wuid/internal"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type workerLeaser struct {
+	newClient NewClient
+	dbName    string
+	coll      string
+}
+
+func (l *workerLeaser) ClaimWorker(ctx context.Context, owner string, ttl time.Duration, horizon int64) (bool, int64, int64, int64, error) {
+	c, done, err := openCollection(l.newClient, l.dbName, l.coll)
+	if err != nil {
+		return false, 0, 0, 0, err
+	}
+	defer done()
+
+	var doc struct {
+		Worker  int64 `bson:"_id"`
+		Token   int64
+		Horizon int64
+	}
+	update := mongo.Pipeline{
+		{{
+			Key: "$set",
+			Value: bson.D{
+				{Key: "owner", Value: bson.D{{Key: "$literal", Value: owner}}},
+				{Key: "token", Value: bson.D{{Key: "$add", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{"$token", int64(0)}}}, int64(1)}}}},
+				{Key: "horizon", Value: bson.D{{Key: "$max", Value: bson.A{"$horizon", horizon}}}},
+				{Key: "expiresAt", Value: expiresAt(ttl)},
+			},
+		}},
+	}
+	expired := bson.D{{Key: "$expr", Value: bson.D{{Key: "$lte", Value: bson.A{"$expiresAt", "$$NOW"}}}}}
+
+	// Take over the lowest worker ID whose lease has expired.
+	var findOneAndUpdateOptions options.FindOneAndUpdateOptions
+	findOneAndUpdateOptions.SetSort(bson.D{{Key: "_id", Value: 1}}).SetReturnDocument(options.Before)
+	err = c.FindOneAndUpdate(ctx, expired, update, &findOneAndUpdateOptions).Decode(&doc)
+	switch {
+	case err == nil:
+		return true, doc.Worker, doc.Token + 1, doc.Horizon, nil
+	case err != mongo.ErrNoDocuments:
+		return false, 0, 0, 0, err
+	}
+
+	// Otherwise, create the lowest worker ID that does not exist yet.
+	var findOptions options.FindOptions
+	findOptions.SetSort(bson.D{{Key: "_id", Value: 1}}).SetProjection(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := c.Find(ctx, bson.D{}, &findOptions)
+	if err != nil {
+		return false, 0, 0, 0, err
+	}
+	var next int64
+	for cursor.Next(ctx) {
+		if err = cursor.Decode(&doc); err != nil {
+			_ = cursor.Close(ctx)
+			return false, 0, 0, 0, err
+		}
+		if doc.Worker != next {
+			break
+		}
+		next++
+	}
+	if err = cursor.Close(ctx); err != nil {
+		return false, 0, 0, 0, err
+	}
+	if next > 1023 {
+		return false, 0, 0, 0, nil
+	}
+
+	// The filter does not match an existing document unless it has expired in the meantime.
+	filter := append(bson.D{{Key: "_id", Value: next}}, expired...)
+	findOneAndUpdateOptions.SetUpsert(true)
+	err = c.FindOneAndUpdate(ctx, filter, update, &findOneAndUpdateOptions).Decode(&doc)
+	switch {
+	case err == mongo.ErrNoDocuments:
+		return true, next, 1, 0, nil
+	case mongo.IsDuplicateKeyError(err):
+		return false, 0, 0, 0, fmt.Errorf("worker %d was claimed by another owner at the same time", next)
+	case err != nil:
+		return false, 0, 0, 0, err
+	}
+	return true, doc.Worker, doc.Token + 1, doc.Horizon, nil
+}
+
+func (l *workerLeaser) RenewWorker(ctx context.Context, worker, token, horizon int64, ttl time.Duration) (bool, error) {
+	c, done, err := openCollection(l.newClient, l.dbName, l.coll)
+	if err != nil {
+		return false, err
+	}
+	defer done()
+
+	filter := bson.D{
+		{Key: "_id", Value: worker},
+		{Key: "token", Value: token},
+	}
+	update := mongo.Pipeline{
+		{{
+			Key: "$set",
+			Value: bson.D{
+				{Key: "horizon", Value: horizon},
+				{Key: "expiresAt", Value: expiresAt(ttl)},
+			},
+		}},
+	}
+	r, err := c.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return r.MatchedCount > 0, nil
+}
+
+// NewWorkerLeaser returns a WorkerLeaser that keeps the worker IDs in a MongoDB collection.
+func NewWorkerLeaser(newClient NewClient, dbName, coll string) WorkerLeaser {
+	return &workerLeaser{newClient: newClient, dbName: dbName, coll: coll}
+}
+
+type WorkerLeaser = internal.WorkerLeaser
+
+// LeaseWorkerFromMongo claims a free worker ID from MongoDB on behalf of owner, and keeps renewing the lease
+// in the background until w is closed. The worker ID stays the same across renewals, and Next panics once
+// the lease expires. It only works with WithSnowflake, and fails if no worker ID is free. The version of
+// MongoDB must be 4.2 or higher.
+func (w *WUID) LeaseWorkerFromMongo(newClient NewClient, dbName, coll, owner string) error {
+	if len(dbName) == 0 {
+		return errors.New("dbName cannot be empty")
+	}
+	if len(coll) == 0 {
+		return errors.New("coll cannot be empty")
+	}
+	return w.w.LeaseWorker(NewWorkerLeaser(newClient, dbName, coll), owner)
+}
@@ -11,43 +11,87 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/readconcern"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"math"
 	"time"
 )
 
-// WUID is an extremely fast universal unique identifier generator.
+// WUID is an extremely fast universal unique identifier generator. The methods and the options
+// shared by all the wuid packages are documented in full in package internal.
 type WUID struct {
 	w *internal.WUID
 }
 
-// NewWUID creates a new WUID instance.
+func unwrap(w *WUID) *internal.WUID {
+	return w.w
+}
+
+// NewWUID creates a new WUID instance. It panics if any option is invalid.
 func NewWUID(name string, logger slog.Logger, opts ...Option) *WUID {
 	return &WUID{w: internal.NewWUID(name, logger, opts...)}
 }
 
+// New creates a new WUID instance in the strict mode. Unlike NewWUID, it returns an *OptionError instead of panicking.
+func New(name string, logger slog.Logger, opts ...Option) (*WUID, error) {
+	w, err := internal.New(name, logger, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &WUID{w: w}, nil
+}
+
 // Next returns a unique identifier.
 func (w *WUID) Next() int64 {
 	return w.w.Next()
 }
 
+// TryNext is like Next, but returns an error instead of panicking.
+func (w *WUID) TryNext() (int64, error) {
+	return w.w.TryNext()
+}
+
+// WaitLoaded blocks until h28 is loaded for the first time, ctx is done or w is closed.
+func (w *WUID) WaitLoaded(ctx context.Context) error {
+	return w.w.WaitLoaded(ctx)
+}
+
+// NextWithTag returns a unique identifier whose low bits are tag. It requires WithStep.
+func (w *WUID) NextWithTag(tag int64) int64 {
+	return w.w.NextWithTag(tag)
+}
+
 type NewClient func() (client *mongo.Client, autoDisconnect bool, err error)
 
-// LoadH28FromMongo adds 1 to a specific number in MongoDB and fetches its new value.
-// The new value is used as the high 28 bits of all generated numbers. In addition, all the
-// arguments passed in are saved for future renewal.
-func (w *WUID) LoadH28FromMongo(newClient NewClient, dbName, coll, docID string) error {
-	if len(dbName) == 0 {
-		return errors.New("dbName cannot be empty")
+type H28Source = internal.H28Source
+type H28Advancer = internal.H28Advancer
+type H28Register = internal.H28Register
+
+type source struct {
+	newClient NewClient
+	dbName    string
+	coll      string
+	docID     string
+}
+
+// NewSource returns an H28Source that adds 1 to a specific number in MongoDB and fetches its new value.
+// It is also an H28Register, so it can be used by a quorum source, and an H28Checker.
+func NewSource(newClient NewClient, dbName, coll, docID string) H28Register {
+	return &source{newClient: newClient, dbName: dbName, coll: coll, docID: docID}
+}
+
+func (s *source) Acquire(ctx context.Context) (int64, error) {
+	if len(s.dbName) == 0 {
+		return 0, errors.New("dbName cannot be empty")
 	}
-	if len(coll) == 0 {
-		return errors.New("coll cannot be empty")
+	if len(s.coll) == 0 {
+		return 0, errors.New("coll cannot be empty")
 	}
-	if len(docID) == 0 {
-		return errors.New("docID cannot be empty")
+	if len(s.docID) == 0 {
+		return 0, errors.New("docID cannot be empty")
 	}
 
-	client, autoDisconnect, err := newClient()
+	client, autoDisconnect, err := s.newClient()
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer func() {
 		if autoDisconnect {
@@ -57,10 +101,8 @@ func (w *WUID) LoadH28FromMongo(newClient NewClient, dbName, coll, docID string)
 		}
 	}()
 
-	ctx1, cancel1 := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel1()
-	if err := client.Ping(ctx1, readpref.Primary()); err != nil {
-		return err
+	if err := client.Ping(ctx, readpref.Primary()); err != nil {
+		return 0, err
 	}
 
 	collOpts := &options.CollectionOptions{
@@ -74,7 +116,7 @@ func (w *WUID) LoadH28FromMongo(newClient NewClient, dbName, coll, docID string)
 	}
 
 	filter := bson.D{
-		{Key: "_id", Value: docID},
+		{Key: "_id", Value: s.docID},
 	}
 	update := bson.D{
 		{
@@ -87,30 +129,251 @@ func (w *WUID) LoadH28FromMongo(newClient NewClient, dbName, coll, docID string)
 
 	var findOneAndUpdateOptions options.FindOneAndUpdateOptions
 	findOneAndUpdateOptions.SetUpsert(true).SetReturnDocument(options.After)
-	c := client.Database(dbName).Collection(coll, collOpts)
-	err = c.FindOneAndUpdate(ctx1, filter, update, &findOneAndUpdateOptions).Decode(&doc)
+	c := client.Database(s.dbName).Collection(s.coll, collOpts)
+	err = c.FindOneAndUpdate(ctx, filter, update, &findOneAndUpdateOptions).Decode(&doc)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	h28 := int64(doc.N)
-	if err = w.w.VerifyH28(h28); err != nil {
+	return int64(doc.N), nil
+}
+
+func (s *source) AdvanceTo(ctx context.Context, h28 int64) error {
+	if len(s.dbName) == 0 {
+		return errors.New("dbName cannot be empty")
+	}
+	if len(s.coll) == 0 {
+		return errors.New("coll cannot be empty")
+	}
+	if len(s.docID) == 0 {
+		return errors.New("docID cannot be empty")
+	}
+	if h28 > math.MaxInt32 {
+		return errors.New("h28 should not exceed math.MaxInt32")
+	}
+
+	client, autoDisconnect, err := s.newClient()
+	if err != nil {
 		return err
 	}
+	defer func() {
+		if autoDisconnect {
+			ctx2, cancel2 := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel2()
+			_ = client.Disconnect(ctx2)
+		}
+	}()
+
+	collOpts := &options.CollectionOptions{
+		ReadConcern:    readconcern.Majority(),
+		WriteConcern:   writeconcern.New(writeconcern.WMajority()),
+		ReadPreference: readpref.Primary(),
+	}
+
+	filter := bson.D{
+		{Key: "_id", Value: s.docID},
+	}
+	update := bson.D{
+		{
+			Key: "$max",
+			Value: bson.D{
+				{Key: "n", Value: int32(h28)},
+			},
+		},
+	}
 
-	w.w.Reset(h28 << 36)
-	w.w.Logger.Infof("<wuid> new h28: %d. name: %s", h28, w.w.Name)
+	var updateOptions options.UpdateOptions
+	updateOptions.SetUpsert(true)
+	c := client.Database(s.dbName).Collection(s.coll, collOpts)
+	_, err = c.UpdateOne(ctx, filter, update, &updateOptions)
+	return err
+}
 
-	w.w.Lock()
-	defer w.w.Unlock()
+func (s *source) Load(ctx context.Context) (int64, error) {
+	if len(s.dbName) == 0 {
+		return 0, errors.New("dbName cannot be empty")
+	}
+	if len(s.coll) == 0 {
+		return 0, errors.New("coll cannot be empty")
+	}
+	if len(s.docID) == 0 {
+		return 0, errors.New("docID cannot be empty")
+	}
 
-	if w.w.Renew != nil {
-		return nil
+	client, autoDisconnect, err := s.newClient()
+	if err != nil {
+		return 0, err
 	}
-	w.w.Renew = func() error {
-		return w.LoadH28FromMongo(newClient, dbName, coll, docID)
+	defer func() {
+		if autoDisconnect {
+			ctx2, cancel2 := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel2()
+			_ = client.Disconnect(ctx2)
+		}
+	}()
+
+	collOpts := &options.CollectionOptions{
+		ReadConcern:    readconcern.Majority(),
+		WriteConcern:   writeconcern.New(writeconcern.WMajority()),
+		ReadPreference: readpref.Primary(),
 	}
 
-	return nil
+	var doc struct {
+		N int64
+	}
+	c := client.Database(s.dbName).Collection(s.coll, collOpts)
+	err = c.FindOne(ctx, bson.D{{Key: "_id", Value: s.docID}}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	return doc.N, err
+}
+
+func (s *source) CompareAndSwap(ctx context.Context, old, new int64) (bool, error) {
+	if len(s.dbName) == 0 {
+		return false, errors.New("dbName cannot be empty")
+	}
+	if len(s.coll) == 0 {
+		return false, errors.New("coll cannot be empty")
+	}
+	if len(s.docID) == 0 {
+		return false, errors.New("docID cannot be empty")
+	}
+	if new > math.MaxInt32 {
+		return false, errors.New("new should not exceed math.MaxInt32")
+	}
+
+	client, autoDisconnect, err := s.newClient()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if autoDisconnect {
+			ctx2, cancel2 := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel2()
+			_ = client.Disconnect(ctx2)
+		}
+	}()
+
+	collOpts := &options.CollectionOptions{
+		ReadConcern:    readconcern.Majority(),
+		WriteConcern:   writeconcern.New(writeconcern.WMajority()),
+		ReadPreference: readpref.Primary(),
+	}
+
+	filter := bson.D{
+		{Key: "_id", Value: s.docID},
+		{Key: "n", Value: old},
+	}
+	update := bson.D{
+		{
+			Key: "$set",
+			Value: bson.D{
+				{Key: "n", Value: int32(new)},
+			},
+		},
+	}
+
+	// A missing document counts as 0, so it is inserted. If the document exists with another value,
+	// the insertion fails with a duplicate key error, which is a lost race as well.
+	var updateOptions options.UpdateOptions
+	updateOptions.SetUpsert(old == 0)
+	c := client.Database(s.dbName).Collection(s.coll, collOpts)
+	result, err := c.UpdateOne(ctx, filter, update, &updateOptions)
+	switch {
+	case mongo.IsDuplicateKeyError(err):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return result.MatchedCount+result.UpsertedCount > 0, nil
+}
+
+// Check makes sure that the document can be updated with the majority write concern, by adding 0
+// to it, and returns its current value. A missing document is created with 0, which changes nothing
+// for Acquire.
+func (s *source) Check(ctx context.Context) (int64, error) {
+	if len(s.dbName) == 0 {
+		return 0, errors.New("dbName cannot be empty")
+	}
+	if len(s.coll) == 0 {
+		return 0, errors.New("coll cannot be empty")
+	}
+	if len(s.docID) == 0 {
+		return 0, errors.New("docID cannot be empty")
+	}
+
+	client, autoDisconnect, err := s.newClient()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if autoDisconnect {
+			ctx2, cancel2 := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel2()
+			_ = client.Disconnect(ctx2)
+		}
+	}()
+
+	if err := client.Ping(ctx, readpref.Primary()); err != nil {
+		return 0, err
+	}
+
+	collOpts := &options.CollectionOptions{
+		ReadConcern:    readconcern.Majority(),
+		WriteConcern:   writeconcern.New(writeconcern.WMajority()),
+		ReadPreference: readpref.Primary(),
+	}
+
+	var doc struct {
+		N int64
+	}
+
+	filter := bson.D{
+		{Key: "_id", Value: s.docID},
+	}
+	update := bson.D{
+		{
+			Key: "$inc",
+			Value: bson.D{
+				{Key: "n", Value: int32(0)},
+			},
+		},
+	}
+
+	var findOneAndUpdateOptions options.FindOneAndUpdateOptions
+	findOneAndUpdateOptions.SetUpsert(true).SetReturnDocument(options.After)
+	c := client.Database(s.dbName).Collection(s.coll, collOpts)
+	err = c.FindOneAndUpdate(ctx, filter, update, &findOneAndUpdateOptions).Decode(&doc)
+	if err != nil {
+		return 0, err
+	}
+	return doc.N, nil
+}
+
+func (s *source) String() string {
+	return "mongo:" + s.dbName + "." + s.coll + "/" + s.docID
+}
+
+// LoadH28FromMongo adds 1 to a specific number in MongoDB and fetches its new value.
+// The new value is used as the high 28 bits of all generated numbers. In addition, all the
+// arguments passed in are saved for future renewal.
+func (w *WUID) LoadH28FromMongo(newClient NewClient, dbName, coll, docID string) error {
+	return w.w.LoadH28FromSource(NewSource(newClient, dbName, coll, docID))
+}
+
+// LoadH28FromMongoContext is like LoadH28FromMongo, but the first round trip is bounded by ctx.
+func (w *WUID) LoadH28FromMongoContext(ctx context.Context, newClient NewClient, dbName, coll, docID string) error {
+	return w.w.LoadH28FromSourceContext(ctx, NewSource(newClient, dbName, coll, docID))
+}
+
+// LoadH28FromSource acquires h28 from src, and saves src for future renewal.
+func (w *WUID) LoadH28FromSource(src H28Source) error {
+	return w.w.LoadH28FromSource(src)
+}
+
+// LoadH28FromSourceContext is like LoadH28FromSource, but the first acquisition is bounded by ctx.
+func (w *WUID) LoadH28FromSourceContext(ctx context.Context, src H28Source) error {
+	return w.w.LoadH28FromSourceContext(ctx, src)
 }
 
 // RenewNow reacquires the high 28 bits immediately.
@@ -118,8 +381,73 @@ func (w *WUID) RenewNow() error {
 	return w.w.RenewNow()
 }
 
+// AdvancePastExclusions advances src past the h28 values rejected by WithMinID and WithExcludedRanges.
+func (w *WUID) AdvancePastExclusions(ctx context.Context, src H28Advancer) (int64, error) {
+	return w.w.AdvancePastExclusions(ctx, src)
+}
+
+type H28Checker = internal.H28Checker
+type CheckResult = internal.CheckResult
+
+// Check verifies src without consuming an h28, and reports the headroom against the h28 limit.
+func (w *WUID) Check(ctx context.Context, src H28Source) (CheckResult, error) {
+	return w.w.Check(ctx, src)
+}
+
+// SampleH28 makes w read the counter of src every interval until w is closed, for Forecast and the alerts.
+func (w *WUID) SampleH28(src H28Source, interval time.Duration) error {
+	return w.w.SampleH28(src, interval)
+}
+
+// WatchControl makes w apply the commands sent through ch until w is closed.
+func (w *WUID) WatchControl(ch ControlChannel, owner string) error {
+	return w.w.WatchControl(ch, owner)
+}
+
+// Close stops all the background activities of w.
+func (w *WUID) Close() {
+	w.w.Close()
+}
+
+type ParsedID = internal.ParsedID
+
+// Parse decodes a number generated by w.
+func (w *WUID) Parse(id int64) ParsedID {
+	return w.w.Parse(id)
+}
+
+type Forecast = internal.Forecast
+
+// Forecast projects when the h28 space and the current block run out.
+func (w *WUID) Forecast() Forecast {
+	return w.w.Forecast()
+}
+
+// Provenance returns the provenance record of the holder of the block that id was generated from.
+func (w *WUID) Provenance(id int64) (*ProvenanceRecord, error) {
+	return w.w.Provenance(id)
+}
+
+// CreatedAt returns roughly when id was generated.
+func (w *WUID) CreatedAt(id int64) (time.Time, bool) {
+	return w.w.CreatedAt(id)
+}
+
+// IDRanges returns the ranges of the IDs generated by w roughly in between [from, to].
+func (w *WUID) IDRanges(from, to time.Time) []IDRange {
+	return w.w.IDRanges(from, to)
+}
+
+// ShardOf returns the shard that id belongs to. It only makes sense with WithModulo.
+func (w *WUID) ShardOf(id int64) int64 {
+	return w.w.ShardOf(id)
+}
+
 type Option = internal.Option
 
+// OptionError collects all the invalid options passed to New.
+type OptionError = internal.OptionError
+
 // WithH28Verifier adds an extra verifier for the high 28 bits.
 func WithH28Verifier(cb func(h28 int64) error) Option {
 	return internal.WithH28Verifier(cb)
@@ -130,12 +458,118 @@ func WithSection(section int8) Option {
 	return internal.WithSection(section)
 }
 
+// WithSectionBits brands a section ID on each generated number, using the highest bits bits other than the sign bit.
+func WithSectionBits(bits int8, section int64) Option {
+	return internal.WithSectionBits(bits, section)
+}
+
 // WithStep sets the step and the floor for each generated number.
 func WithStep(step int64, floor int64) Option {
 	return internal.WithStep(step, floor)
 }
 
+// WithModulo makes each generated number satisfy id%n == k.
+func WithModulo(n, k int64) Option {
+	return internal.WithModulo(n, k)
+}
+
 // WithObfuscation enables number obfuscation.
 func WithObfuscation(seed int) Option {
 	return internal.WithObfuscation(seed)
 }
+
+// WithTimestamp embeds a coarse timestamp, which is counted in unit since epoch, in each generated number.
+func WithTimestamp(epoch time.Time, unit time.Duration) Option {
+	return internal.WithTimestamp(epoch, unit)
+}
+
+// WithSnowflake switches to the Snowflake layout, whose worker ID is leased by LeaseWorkerFromMongo.
+func WithSnowflake(epoch time.Time, lease time.Duration) Option {
+	return internal.WithSnowflake(epoch, lease)
+}
+
+// WithRenewTimeout sets the timeout of each renewal, which defaults to 5 seconds.
+func WithRenewTimeout(timeout time.Duration) Option {
+	return internal.WithRenewTimeout(timeout)
+}
+
+// WithStrict enables or disables the strict mode. See New for details.
+func WithStrict(strict bool) Option {
+	return internal.WithStrict(strict)
+}
+
+// WithReserve keeps size h28 values acquired ahead of time in the file at path, in case the data source fails.
+func WithReserve(path string, size int, ttl time.Duration) Option {
+	return internal.WithReserve(path, size, ttl)
+}
+
+type IDRange = internal.IDRange
+
+// WithMinID makes VerifyH28 reject any h28 that could produce a number less than min.
+func WithMinID(min int64) Option {
+	return internal.WithMinID(min)
+}
+
+// WithExcludedRanges makes VerifyH28 reject any h28 whose numbers would intersect one of ranges.
+func WithExcludedRanges(ranges ...IDRange) Option {
+	return internal.WithExcludedRanges(ranges...)
+}
+
+type Journal = internal.Journal
+
+// ErrRollback indicates that the data source handed out an h28 that is not greater than the high-water mark.
+var ErrRollback = internal.ErrRollback
+
+// OpenJournal opens the journal at path, and creates it if it does not exist.
+func OpenJournal(path string) (*Journal, error) {
+	return internal.OpenJournal(path)
+}
+
+// WithJournal records every accepted h28 in j, and rejects a rollback of the data source with ErrRollback.
+func WithJournal(j *Journal) Option {
+	return internal.WithJournal(j)
+}
+
+type Event = internal.Event
+type EventKind = internal.EventKind
+
+const (
+	EventRollback = internal.EventRollback
+	EventHeadroom = internal.EventHeadroom
+)
+
+// WithEventHandler sets a function that is called on noteworthy events. It should return quickly.
+func WithEventHandler(handler func(e Event)) Option {
+	return internal.WithEventHandler(handler)
+}
+
+// WithHeadroomAlerts makes w raise an EventHeadroom when the h28 space left drops to one of thresholds.
+func WithHeadroomAlerts(thresholds ...float64) Option {
+	return internal.WithHeadroomAlerts(thresholds...)
+}
+
+type ProvenanceRecord = internal.ProvenanceRecord
+type ProvenanceStore = internal.ProvenanceStore
+
+// ErrNoProvenance indicates that there is no provenance record for an ID.
+var ErrNoProvenance = internal.ErrNoProvenance
+
+// WithProvenance makes w record in store who takes each h28.
+func WithProvenance(store ProvenanceStore, version string) Option {
+	return internal.WithProvenance(store, version)
+}
+
+type Command = internal.Command
+
+const (
+	CommandRenew  = internal.CommandRenew
+	CommandPause  = internal.CommandPause
+	CommandResume = internal.CommandResume
+)
+
+type ControlState = internal.ControlState
+type ControlAck = internal.ControlAck
+type ControlChannel = internal.ControlChannel
+
+// ControlPollInterval bounds how late a generator may apply a command if a notification is missed.
+const ControlPollInterval = internal.ControlPollInterval
@@ -175,6 +175,87 @@ func TestWUID_Renew(t *testing.T) {
 	}
 }
 
+func TestSource_AdvanceTo(t *testing.T) {
+	client, err := connectMongodb()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newClient := func() (*mongo.Client, bool, error) {
+		return client, false, nil
+	}
+
+	src := NewSource(newClient, cfg.dbName, cfg.coll, cfg.docID+"-advance")
+	h28, err := src.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := src.AdvanceTo(context.Background(), h28+100); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.AdvanceTo(context.Background(), h28); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := src.Acquire(context.Background()); err != nil || v != h28+101 {
+		t.Fatalf("AdvanceTo does not work as expected. v: %d, err: %v", v, err)
+	}
+}
+
+func TestSource_CompareAndSwap(t *testing.T) {
+	client, err := connectMongodb()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newClient := func() (*mongo.Client, bool, error) {
+		return client, false, nil
+	}
+
+	src := NewSource(newClient, cfg.dbName, cfg.coll, cfg.docID+"-cas")
+	h28, err := src.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := src.Load(context.Background()); err != nil || v != h28 {
+		t.Fatalf("Load does not work as expected. v: %d, err: %v", v, err)
+	}
+	if ok, err := src.CompareAndSwap(context.Background(), h28-1, h28+10); err != nil || ok {
+		t.Fatalf("CompareAndSwap should fail when the value is different. ok: %v, err: %v", ok, err)
+	}
+	if ok, err := src.CompareAndSwap(context.Background(), h28, h28+10); err != nil || !ok {
+		t.Fatalf("CompareAndSwap does not work as expected. ok: %v, err: %v", ok, err)
+	}
+	if v, err := src.Acquire(context.Background()); err != nil || v != h28+11 {
+		t.Fatalf("CompareAndSwap does not work as expected. v: %d, err: %v", v, err)
+	}
+}
+
+func TestWUID_Check(t *testing.T) {
+	client, err := connectMongodb()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newClient := func() (*mongo.Client, bool, error) {
+		return client, false, nil
+	}
+
+	src := NewSource(newClient, cfg.dbName, cfg.coll, cfg.docID+"-check")
+	h28, err := src.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewWUID("alpha", dumb)
+	r, err := w.Check(context.Background(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Current != h28 || r.Headroom <= 0 {
+		t.Fatalf("wrong check result. h28: %d, current: %d, headroom: %f", h28, r.Current, r.Headroom)
+	}
+	if v, err := src.Acquire(context.Background()); err != nil || v != h28+1 {
+		t.Fatalf("Check should not consume an h28. v: %d, err: %v", v, err)
+	}
+}
+
 func Example() {
 	newClient := func() (*mongo.Client, bool, error) {
 		var client *mongo.Client
@@ -8,3 +8,50 @@ CREATE TABLE IF NOT EXISTS `wuid` (
     PRIMARY KEY (`x`),
     UNIQUE KEY `h` (`h`)
 ) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+CREATE TABLE IF NOT EXISTS `wuid_section` (
+    `section` int(10) NOT NULL,
+    `owner` varchar(255) NOT NULL,
+    `expires_at` datetime(3) NOT NULL,
+    `h28` bigint(20) NOT NULL DEFAULT '0',
+    PRIMARY KEY (`section`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+CREATE TABLE IF NOT EXISTS `wuid_block` (
+    `h28` bigint(20) NOT NULL,
+    `owner` varchar(255) NOT NULL,
+    `token` bigint(20) NOT NULL,
+    `checkpoint` bigint(20) NOT NULL,
+    `expires_at` datetime(3) NOT NULL,
+    PRIMARY KEY (`h28`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+CREATE TABLE IF NOT EXISTS `wuid_worker` (
+    `worker` int(10) NOT NULL,
+    `owner` varchar(255) NOT NULL,
+    `token` bigint(20) NOT NULL,
+    `horizon` bigint(20) NOT NULL,
+    `expires_at` datetime(3) NOT NULL,
+    PRIMARY KEY (`worker`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+CREATE TABLE IF NOT EXISTS `wuid_provenance` (
+    `h28` bigint(20) NOT NULL,
+    `start` bigint(20) NOT NULL,
+    `name` varchar(255) NOT NULL,
+    `hostname` varchar(255) NOT NULL,
+    `pid` int(10) NOT NULL,
+    `version` varchar(255) NOT NULL,
+    `created_at` bigint(20) NOT NULL,
+    PRIMARY KEY (`h28`, `start`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+CREATE TABLE IF NOT EXISTS `wuid_control` (
+    `name` varchar(255) NOT NULL,
+    `owner` varchar(255) NOT NULL,
+    `renew_seq` bigint(20) NOT NULL DEFAULT '0',
+    `paused` tinyint(1) NOT NULL DEFAULT '0',
+    `error` varchar(1024) NOT NULL DEFAULT '',
+    `updated_at` bigint(20) NOT NULL,
+    PRIMARY KEY (`name`, `owner`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
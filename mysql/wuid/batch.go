@@ -0,0 +1,99 @@
+package wuid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/edwingeng/wuid/internal"
+	"strings"
+)
+
+// LoadH28FromMysqlInBatch is like LoadH28FromMysql, but loads h28 for all of ws in one round trip.
+// tables[i] is the table of ws[i]. It sends all the statements in a single query, so the DSN must
+// have multiStatements=true. The generators that fail are left untouched, and the first error is returned.
+// Each generator resumes a leased block or falls back to its reserve just like LoadH28FromMysql.
+// The round trip is bounded by the longest RenewTimeout of ws.
+func LoadH28FromMysqlInBatch(openDB OpenDB, ws []*WUID, tables []string) error {
+	ctx1, cancel1 := context.WithTimeout(context.Background(), internal.MaxRenewTimeout(ws, unwrap))
+	defer cancel1()
+	return LoadH28FromMysqlInBatchContext(ctx1, openDB, ws, tables)
+}
+
+// LoadH28FromMysqlInBatchContext is like LoadH28FromMysqlInBatch, but the round trip is bounded by ctx.
+func LoadH28FromMysqlInBatchContext(ctx context.Context, openDB OpenDB, ws []*WUID, tables []string) error {
+	if len(ws) != len(tables) {
+		return errors.New("ws and tables should have the same length")
+	}
+	for _, table := range tables {
+		if len(table) == 0 {
+			return errors.New("table cannot be empty")
+		}
+	}
+	if len(ws) == 0 {
+		return nil
+	}
+
+	h28s, err := replaceInBatch(ctx, openDB, tables)
+	var firstErr error
+	for i, w := range ws {
+		w, table := w, tables[i]
+		var h28 int64
+		acqErr := err
+		if acqErr == nil {
+			h28 = h28s[i]
+		}
+		if err := w.w.LoadAcquiredH28(ctx, NewSource(openDB, table), h28, acqErr); err != nil {
+			w.w.Warnf("<wuid> failed to load h28. name: %s, table: %s, reason: %+v", w.w.Name, table, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to load h28. name: %s, table: %s, reason: %w", w.w.Name, table, err)
+			}
+		}
+	}
+	return firstErr
+}
+
+// replaceInBatch increments the counters of tables in a single query.
+func replaceInBatch(ctx context.Context, openDB OpenDB, tables []string) ([]int64, error) {
+	db, autoClose, err := openDB()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if autoClose {
+			_ = db.Close()
+		}
+	}()
+
+	var sb strings.Builder
+	for _, table := range tables {
+		_, _ = fmt.Fprintf(&sb, "REPLACE INTO %s (x) VALUES (0); SELECT LAST_INSERT_ID(); ", table)
+	}
+
+	// The connection must not change between REPLACE and LAST_INSERT_ID, which holds within a single query.
+	rows, err := db.QueryContext(ctx, sb.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	h28s := make([]int64, 0, len(tables))
+	for {
+		for rows.Next() {
+			var h28 int64
+			if err := rows.Scan(&h28); err != nil {
+				return nil, err
+			}
+			h28s = append(h28s, h28)
+		}
+		if !rows.NextResultSet() {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(h28s) != len(tables) {
+		return nil, fmt.Errorf("unexpected number of results. expected: %d, actual: %d", len(tables), len(h28s))
+	}
+	return h28s, nil
+}
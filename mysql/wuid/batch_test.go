@@ -0,0 +1,68 @@
+package wuid
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func connectWithMultiStatements() (*sql.DB, error) {
+	dsn := cfg.user
+	if len(cfg.pass) > 0 {
+		dsn += ":" + cfg.pass
+	}
+	dsn += "@tcp(" + cfg.addr + ")/" + cfg.dbName + "?multiStatements=true"
+	return sql.Open("mysql", dsn)
+}
+
+func TestLoadH28FromMysqlInBatch(t *testing.T) {
+	db, err := connectWithMultiStatements()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	openDB := func() (*sql.DB, bool, error) {
+		return db, false, nil
+	}
+
+	ws := []*WUID{NewWUID("alpha", dumb), NewWUID("beta", dumb)}
+	tables := []string{cfg.table, cfg.table}
+	if err := LoadH28FromMysqlInBatch(openDB, ws, tables); err != nil {
+		t.Fatal(err)
+	}
+	h1, h2 := ws[0].Parse(ws[0].Next()).H28, ws[1].Parse(ws[1].Next()).H28
+	if h2 != h1+1 {
+		t.Fatalf("the statements are not executed in order. h1: %d, h2: %d", h1, h2)
+	}
+	for _, w := range ws {
+		if w.w.Renew == nil {
+			t.Fatal(`w.w.Renew == nil`)
+		}
+	}
+
+	if err := LoadH28FromMysqlInBatch(openDB, ws, tables[:1]); err == nil {
+		t.Fatal("LoadH28FromMysqlInBatch should fail when the lengths differ")
+	}
+}
+
+func TestRegistry_Preload(t *testing.T) {
+	db, err := connectWithMultiStatements()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	openDB := func() (*sql.DB, bool, error) {
+		return db, false, nil
+	}
+
+	r := NewRegistry(openDB, "%s", dumb)
+	defer r.Close()
+	if err := r.Preload(cfg.table, cfg.table); err != nil {
+		t.Fatal(err)
+	}
+	if stats := r.Stats(); stats.NumGenerators != 1 {
+		t.Fatalf("Preload does not work as expected. stats: %+v", stats)
+	}
+	if err := r.Preload("wuid; DROP TABLE wuid"); err == nil {
+		t.Fatal("Preload should reject invalid names")
+	}
+}
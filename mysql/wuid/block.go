@@ -0,0 +1,135 @@
+package wuid
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/edwingeng/wuid/internal"
+	"time"
+)
+
+type blockLeaser struct {
+	openDB OpenDB
+	table  string
+}
+
+func (l *blockLeaser) ClaimBlock(ctx context.Context, owner string, ttl time.Duration, below int64) (bool, int64, int64, int64, error) {
+	db, autoClose, err := l.openDB()
+	if err != nil {
+		return false, 0, 0, 0, err
+	}
+	defer func() {
+		if autoClose {
+			_ = db.Close()
+		}
+	}()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, 0, 0, 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var h28, checkpoint, token int64
+	query := fmt.Sprintf("SELECT h28, checkpoint, token FROM %s WHERE (owner = ? OR expires_at <= NOW(3)) AND checkpoint < ? ORDER BY h28 LIMIT 1 FOR UPDATE", l.table)
+	err = tx.QueryRowContext(ctx, query, owner, below).Scan(&h28, &checkpoint, &token)
+	switch {
+	case err == sql.ErrNoRows:
+		return false, 0, 0, 0, nil
+	case err != nil:
+		return false, 0, 0, 0, err
+	}
+	token++
+	stmt := fmt.Sprintf("UPDATE %s SET owner = ?, token = ?, expires_at = NOW(3) + INTERVAL ? MICROSECOND WHERE h28 = ?", l.table)
+	if _, err = tx.ExecContext(ctx, stmt, owner, token, ttl.Microseconds(), h28); err != nil {
+		return false, 0, 0, 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return false, 0, 0, 0, err
+	}
+	return true, h28, checkpoint, token, nil
+}
+
+func (l *blockLeaser) SaveBlock(ctx context.Context, owner string, h28, token, checkpoint int64, ttl time.Duration) (bool, error) {
+	db, autoClose, err := l.openDB()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if autoClose {
+			_ = db.Close()
+		}
+	}()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var current int64
+	query := fmt.Sprintf("SELECT token FROM %s WHERE h28 = ? FOR UPDATE", l.table)
+	err = tx.QueryRowContext(ctx, query, h28).Scan(&current)
+	switch {
+	case err == sql.ErrNoRows && token == 1:
+		stmt := fmt.Sprintf("INSERT INTO %s (h28, owner, token, checkpoint, expires_at) VALUES (?, ?, ?, ?, NOW(3) + INTERVAL ? MICROSECOND)", l.table)
+		_, err = tx.ExecContext(ctx, stmt, h28, owner, token, checkpoint, ttl.Microseconds())
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		return false, err
+	case current != token:
+		return false, nil
+	default:
+		stmt := fmt.Sprintf("UPDATE %s SET owner = ?, checkpoint = ?, expires_at = NOW(3) + INTERVAL ? MICROSECOND WHERE h28 = ?", l.table)
+		_, err = tx.ExecContext(ctx, stmt, owner, checkpoint, ttl.Microseconds(), h28)
+	}
+	if err != nil {
+		return false, err
+	}
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (l *blockLeaser) DropBlock(ctx context.Context, h28, token int64) error {
+	db, autoClose, err := l.openDB()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if autoClose {
+			_ = db.Close()
+		}
+	}()
+
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE h28 = ? AND token = ?", l.table)
+	_, err = db.ExecContext(ctx, stmt, h28, token)
+	return err
+}
+
+// NewBlockLeaser returns a BlockLeaser that keeps the blocks in a MySQL table.
+func NewBlockLeaser(openDB OpenDB, table string) BlockLeaser {
+	return &blockLeaser{openDB: openDB, table: table}
+}
+
+type BlockLeaser = internal.BlockLeaser
+
+// LeaseBlocksFromMysql makes w record in MySQL the checkpoint of every h28 block it loads, on behalf of
+// owner, which should survive restarts, e.g. a pod name. When h28 is loaded for the first time, w resumes
+// the unused tail of a block whose lease has expired or is held by owner, instead of acquiring a new one.
+// Next never passes the recorded checkpoint, so two holders of a block can never overlap. Close records
+// the exact position and releases the lease. The blocks must be leased before h28 is loaded, and each
+// table of h28 needs its own table of blocks.
+func (w *WUID) LeaseBlocksFromMysql(openDB OpenDB, table, owner string, ttl time.Duration) error {
+	if len(table) == 0 {
+		return errors.New("table cannot be empty")
+	}
+	return w.w.LeaseBlocks(NewBlockLeaser(openDB, table), owner, ttl)
+}
@@ -0,0 +1,58 @@
+package wuid
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestWUID_LeaseBlocksFromMysql(t *testing.T) {
+	openDB := func() (*sql.DB, bool, error) {
+		db, err := connect()
+		return db, true, err
+	}
+
+	db, err := connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("DELETE FROM wuid_block"); err != nil {
+		t.Fatal(err)
+	}
+
+	w1 := NewWUID("alpha", dumb)
+	if err := w1.LeaseBlocksFromMysql(openDB, "wuid_block", "pod-1", time.Second*10); err != nil {
+		t.Fatal(err)
+	}
+	if err := w1.LoadH28FromMysql(openDB, cfg.table); err != nil {
+		t.Fatal(err)
+	}
+	w1.Next()
+	last := w1.Next()
+	w1.Close()
+
+	w2 := NewWUID("alpha", dumb)
+	defer w2.Close()
+	if err := w2.LeaseBlocksFromMysql(openDB, "wuid_block", "pod-2", time.Second*10); err != nil {
+		t.Fatal(err)
+	}
+	if err := w2.LoadH28FromMysql(openDB, cfg.table); err != nil {
+		t.Fatal(err)
+	}
+	if v := w2.Next(); v != last+1 {
+		t.Fatalf("the block is not resumed. v: %d, last: %d", v, last)
+	}
+
+	w3 := NewWUID("alpha", dumb)
+	defer w3.Close()
+	if err := w3.LeaseBlocksFromMysql(openDB, "wuid_block", "pod-3", time.Second*10); err != nil {
+		t.Fatal(err)
+	}
+	if err := w3.LoadH28FromMysql(openDB, cfg.table); err != nil {
+		t.Fatal(err)
+	}
+	if w3.Parse(w3.Next()).H28 == w2.Parse(w2.Next()).H28 {
+		t.Fatal("a leased block should never be shared")
+	}
+}
@@ -0,0 +1,129 @@
+package wuid
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type controlChannel struct {
+	openDB OpenDB
+	table  string
+	name   string
+}
+
+// NewControlChannel returns a ControlChannel that keeps the state and the acknowledgements of name
+// in a MySQL table. The state is the row whose owner is empty. The watchers poll the state, so
+// a command is applied within ControlPollInterval.
+func NewControlChannel(openDB OpenDB, table, name string) ControlChannel {
+	return &controlChannel{openDB: openDB, table: table, name: name}
+}
+
+func (c *controlChannel) Send(ctx context.Context, cmd Command) error {
+	db, autoClose, err := c.openDB()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if autoClose {
+			_ = db.Close()
+		}
+	}()
+
+	var renewSeq, paused int
+	var update string
+	switch cmd {
+	case CommandRenew:
+		renewSeq, update = 1, "renew_seq = renew_seq + 1"
+	case CommandPause:
+		paused, update = 1, "paused = 1"
+	case CommandResume:
+		paused, update = 0, "paused = 0"
+	default:
+		return errors.New("unknown command")
+	}
+	stmt := fmt.Sprintf("INSERT INTO %s (name, owner, renew_seq, paused, error, updated_at) VALUES (?, '', ?, ?, '', ?) ON DUPLICATE KEY UPDATE %s, updated_at = VALUES(updated_at)", c.table, update)
+	_, err = db.ExecContext(ctx, stmt, c.name, renewSeq, paused, time.Now().UnixMilli())
+	return err
+}
+
+func (c *controlChannel) State(ctx context.Context) (ControlState, error) {
+	db, autoClose, err := c.openDB()
+	if err != nil {
+		return ControlState{}, err
+	}
+	defer func() {
+		if autoClose {
+			_ = db.Close()
+		}
+	}()
+
+	var state ControlState
+	query := fmt.Sprintf("SELECT renew_seq, paused FROM %s WHERE name = ? AND owner = ''", c.table)
+	err = db.QueryRowContext(ctx, query, c.name).Scan(&state.RenewSeq, &state.Paused)
+	if err != nil && err != sql.ErrNoRows {
+		return ControlState{}, err
+	}
+	return state, nil
+}
+
+func (c *controlChannel) Wait(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (c *controlChannel) Ack(ctx context.Context, ack ControlAck) error {
+	if len(ack.Owner) == 0 {
+		return errors.New("owner cannot be empty")
+	}
+
+	db, autoClose, err := c.openDB()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if autoClose {
+			_ = db.Close()
+		}
+	}()
+
+	if len(ack.Err) > 1024 {
+		ack.Err = ack.Err[:1024]
+	}
+	stmt := fmt.Sprintf("REPLACE INTO %s (name, owner, renew_seq, paused, error, updated_at) VALUES (?, ?, ?, ?, ?, ?)", c.table)
+	_, err = db.ExecContext(ctx, stmt, c.name, ack.Owner, ack.RenewSeq, ack.Paused, ack.Err, ack.Time.UnixMilli())
+	return err
+}
+
+func (c *controlChannel) Acks(ctx context.Context) ([]ControlAck, error) {
+	db, autoClose, err := c.openDB()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if autoClose {
+			_ = db.Close()
+		}
+	}()
+
+	query := fmt.Sprintf("SELECT owner, renew_seq, paused, error, updated_at FROM %s WHERE name = ? AND owner <> ''", c.table)
+	rows, err := db.QueryContext(ctx, query, c.name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var acks []ControlAck
+	for rows.Next() {
+		var ack ControlAck
+		var updatedAt int64
+		if err := rows.Scan(&ack.Owner, &ack.RenewSeq, &ack.Paused, &ack.Err, &updatedAt); err != nil {
+			return nil, err
+		}
+		ack.Time = time.UnixMilli(updatedAt)
+		acks = append(acks, ack)
+	}
+	return acks, rows.Err()
+}
@@ -0,0 +1,84 @@
+package wuid
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/edwingeng/wuid/internal"
+)
+
+type partitionSource struct {
+	openDB OpenDB
+	table  string
+	n      int64
+	i      int64
+}
+
+// NewPartitionSource returns an H28Source that only hands out numbers congruent to i modulo n,
+// i.e. the i-th partition of n, by means of auto_increment_increment and auto_increment_offset.
+// Each partition should be kept by an independent MySQL. n must be in between [1, 1024], and i
+// must be in between [0, n).
+func NewPartitionSource(openDB OpenDB, table string, n, i int64) H28Source {
+	internal.CheckPartition(n, i)
+	return &partitionSource{openDB: openDB, table: table, n: n, i: i}
+}
+
+func (s *partitionSource) Acquire(ctx context.Context) (int64, error) {
+	if len(s.table) == 0 {
+		return 0, errors.New("table cannot be empty")
+	}
+
+	db, autoClose, err := s.openDB()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if autoClose {
+			_ = db.Close()
+		}
+	}()
+
+	// The session variables only take effect on the same connection.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	offset := s.i
+	if offset == 0 {
+		offset = s.n
+	}
+	q1 := fmt.Sprintf("SET SESSION auto_increment_increment = %d, auto_increment_offset = %d", s.n, offset)
+	if _, err := conn.ExecContext(ctx, q1); err != nil {
+		return 0, err
+	}
+	defer func() {
+		// A connection that cannot be reset must not go back to the pool with the session variables.
+		q2 := "SET SESSION auto_increment_increment = DEFAULT, auto_increment_offset = DEFAULT"
+		if _, err := conn.ExecContext(ctx, q2); err != nil {
+			_ = conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+	}()
+
+	result, err := conn.ExecContext(ctx, fmt.Sprintf("REPLACE INTO %s (x) VALUES (0)", s.table))
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// Partition returns n and i, so that the generators verify the residue of each h28.
+func (s *partitionSource) Partition() (n, i int64) {
+	return s.n, s.i
+}
+
+// Check is the same as the Check of the source returned by NewSource.
+func (s *partitionSource) Check(ctx context.Context) (int64, error) {
+	return (&source{openDB: s.openDB, table: s.table}).Check(ctx)
+}
+
+func (s *partitionSource) String() string {
+	return "mysql:" + s.table
+}
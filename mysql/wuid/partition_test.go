@@ -0,0 +1,39 @@
+package wuid
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func TestNewPartitionSource(t *testing.T) {
+	db, err := connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	openDB := func() (*sql.DB, bool, error) {
+		return db, false, nil
+	}
+
+	src := NewPartitionSource(openDB, cfg.table, 3, 2)
+	var last int64
+	for i := 0; i < 3; i++ {
+		h28, err := src.Acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h28%3 != 2 || h28 <= last {
+			t.Fatalf("h28 is not in the partition. h28: %d, last: %d", h28, last)
+		}
+		last = h28
+	}
+
+	var increment int64
+	if err := db.QueryRow("SELECT @@SESSION.auto_increment_increment").Scan(&increment); err != nil {
+		t.Fatal(err)
+	}
+	if increment != 1 {
+		t.Fatal("the session variables are not restored")
+	}
+}
@@ -0,0 +1,65 @@
+package wuid
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type provenanceStore struct {
+	openDB OpenDB
+	table  string
+}
+
+// NewProvenanceStore returns a ProvenanceStore that keeps the records in a MySQL side table,
+// one row for each holder of each h28. created_at is in milliseconds since the Unix epoch.
+func NewProvenanceStore(openDB OpenDB, table string) ProvenanceStore {
+	return &provenanceStore{openDB: openDB, table: table}
+}
+
+func (s *provenanceStore) Record(ctx context.Context, h28 int64, rec ProvenanceRecord) error {
+	db, autoClose, err := s.openDB()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if autoClose {
+			_ = db.Close()
+		}
+	}()
+
+	stmt := fmt.Sprintf("REPLACE INTO %s (h28, start, name, hostname, pid, version, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)", s.table)
+	_, err = db.ExecContext(ctx, stmt, h28, rec.Start, rec.Name, rec.Hostname, rec.PID, rec.Version, rec.Time.UnixMilli())
+	return err
+}
+
+func (s *provenanceStore) Lookup(ctx context.Context, h28 int64) ([]ProvenanceRecord, error) {
+	db, autoClose, err := s.openDB()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if autoClose {
+			_ = db.Close()
+		}
+	}()
+
+	query := fmt.Sprintf("SELECT start, name, hostname, pid, version, created_at FROM %s WHERE h28 = ? ORDER BY start", s.table)
+	rows, err := db.QueryContext(ctx, query, h28)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []ProvenanceRecord
+	for rows.Next() {
+		var rec ProvenanceRecord
+		var createdAt int64
+		if err := rows.Scan(&rec.Start, &rec.Name, &rec.Hostname, &rec.PID, &rec.Version, &createdAt); err != nil {
+			return nil, err
+		}
+		rec.Time = time.UnixMilli(createdAt)
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
@@ -0,0 +1,27 @@
+package wuid
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+)
+
+func TestWithProvenance(t *testing.T) {
+	openDB := func() (*sql.DB, bool, error) {
+		db, err := connect()
+		return db, true, err
+	}
+
+	store := NewProvenanceStore(openDB, "wuid_provenance")
+	w := NewWUID("alpha", dumb, WithProvenance(store, "v1.2.3"))
+	if err := w.LoadH28FromMysql(openDB, cfg.table); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := w.Provenance(w.Next())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Name != "alpha" || rec.PID != os.Getpid() || rec.Version != "v1.2.3" {
+		t.Fatalf("unexpected record: %+v", rec)
+	}
+}
@@ -0,0 +1,75 @@
+package wuid
+
+import (
+	"fmt"
+	"github.com/edwingeng/slog"
+	"github.com/edwingeng/wuid/internal"
+	"regexp"
+)
+
+var validName = regexp.MustCompile(`^[0-9A-Za-z_]+$`)
+
+// Registry creates generators by name on demand. All the generators share the same MySQL configuration,
+// and the table of each generator is made by tableTemplate, e.g. "wuid_%s".
+type Registry struct {
+	r internal.Registry[*WUID]
+}
+
+// NewRegistry creates a new Registry. opts are applied to each generator. A name may only contain
+// letters, digits and underscores, and all the tables must be created beforehand.
+func NewRegistry(openDB OpenDB, tableTemplate string, logger slog.Logger, opts ...Option) *Registry {
+	reg := &Registry{}
+	reg.r.New = func(name string) (*WUID, error) {
+		if !validName.MatchString(name) {
+			return nil, fmt.Errorf("name should only contain letters, digits and underscores. name: %s", name)
+		}
+		w := NewWUID(name, logger, opts...)
+		if err := w.LoadH28FromMysql(openDB, fmt.Sprintf(tableTemplate, name)); err != nil {
+			return nil, err
+		}
+		return w, nil
+	}
+	reg.r.NewInBatch = func(names []string) ([]*WUID, error) {
+		ws := make([]*WUID, len(names))
+		tables := make([]string, len(names))
+		for i, name := range names {
+			if !validName.MatchString(name) {
+				return nil, fmt.Errorf("name should only contain letters, digits and underscores. name: %s", name)
+			}
+			ws[i] = NewWUID(name, logger, opts...)
+			tables[i] = fmt.Sprintf(tableTemplate, name)
+		}
+		if err := LoadH28FromMysqlInBatch(openDB, ws, tables); err != nil {
+			for _, w := range ws {
+				w.Close()
+			}
+			return nil, err
+		}
+		return ws, nil
+	}
+	reg.r.Unwrap = unwrap
+	return reg
+}
+
+// Get returns the generator named name. The generator is created and loaded on the first call.
+func (r *Registry) Get(name string) (*WUID, error) {
+	return r.r.Get(name)
+}
+
+// Preload creates and loads the generators of names in one round trip, so that the following Get
+// calls on them return immediately. The DSN must have multiStatements=true.
+func (r *Registry) Preload(names ...string) error {
+	return r.r.Preload(names...)
+}
+
+type RegistryStats = internal.RegistryStats
+
+// Stats returns the aggregate statistics of all the generators.
+func (r *Registry) Stats() RegistryStats {
+	return r.r.Stats()
+}
+
+// Close closes all the generators. Get fails after Close.
+func (r *Registry) Close() {
+	r.r.Close()
+}
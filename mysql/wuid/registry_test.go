@@ -0,0 +1,37 @@
+package wuid
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	db, err := connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	openDB := func() (*sql.DB, bool, error) {
+		return db, false, nil
+	}
+
+	r := NewRegistry(openDB, "%s", dumb)
+	w1, err := r.Get(cfg.table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w2, _ := r.Get(cfg.table); w2 != w1 {
+		t.Fatal("Get does not work as expected")
+	}
+	if _, err := r.Get("wuid; DROP TABLE wuid"); err == nil {
+		t.Fatal("Get should reject invalid names")
+	}
+	if stats := r.Stats(); stats.NumGenerators != 1 {
+		t.Fatalf("Stats does not work as expected. stats: %+v", stats)
+	}
+
+	r.Close()
+	if _, err := r.Get(cfg.table); err == nil {
+		t.Fatal("Get should fail after Close")
+	}
+}
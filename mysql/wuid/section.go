@@ -0,0 +1,74 @@
+package wuid
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type sectionLeaser struct {
+	openDB OpenDB
+	table  string
+}
+
+func (l *sectionLeaser) LeaseSection(ctx context.Context, section int64, owner string, ttl time.Duration, h28 int64, claim bool) (bool, int64, error) {
+	db, autoClose, err := l.openDB()
+	if err != nil {
+		return false, 0, err
+	}
+	defer func() {
+		if autoClose {
+			_ = db.Close()
+		}
+	}()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var current string
+	var alive bool
+	var maxH28 int64
+	query := fmt.Sprintf("SELECT owner, expires_at > NOW(3), h28 FROM %s WHERE section = ? FOR UPDATE", l.table)
+	err = tx.QueryRowContext(ctx, query, section).Scan(&current, &alive, &maxH28)
+	switch {
+	case err == sql.ErrNoRows && !claim:
+		return false, 0, nil
+	case err == sql.ErrNoRows:
+		stmt := fmt.Sprintf("INSERT INTO %s (section, owner, expires_at, h28) VALUES (?, ?, NOW(3) + INTERVAL ? MICROSECOND, ?)", l.table)
+		_, err = tx.ExecContext(ctx, stmt, section, owner, ttl.Microseconds(), h28)
+	case err != nil:
+		return false, 0, err
+	case current != owner && alive:
+		return false, 0, nil
+	case (current != owner || !alive) && !claim:
+		return false, 0, nil
+	default:
+		stmt := fmt.Sprintf("UPDATE %s SET owner = ?, expires_at = NOW(3) + INTERVAL ? MICROSECOND, h28 = GREATEST(h28, ?) WHERE section = ?", l.table)
+		_, err = tx.ExecContext(ctx, stmt, owner, ttl.Microseconds(), h28, section)
+	}
+	if err != nil {
+		return false, 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return false, 0, err
+	}
+	return true, maxH28, nil
+}
+
+// LeaseSectionFromMysql joins the section held by owner, which usually identifies a cluster, or claims a free one,
+// and keeps renewing the lease in the background until w is closed. Next panics if the lease is lost.
+// The width of the section field is specified by WithSectionBits, and defaults to 3 bits.
+// The section must be leased before h28 is loaded.
+func (w *WUID) LeaseSectionFromMysql(openDB OpenDB, table, owner string, ttl time.Duration) error {
+	if len(table) == 0 {
+		return errors.New("table cannot be empty")
+	}
+	return w.w.LeaseSection(&sectionLeaser{openDB: openDB, table: table}, owner, ttl)
+}
@@ -0,0 +1,38 @@
+package wuid
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestWUID_LeaseSectionFromMysql(t *testing.T) {
+	openDB := func() (*sql.DB, bool, error) {
+		db, err := connect()
+		return db, true, err
+	}
+
+	owner := fmt.Sprintf("alpha-%d", rand.Int63())
+	w1 := NewWUID("alpha", dumb, WithSectionBits(16, 0))
+	defer w1.Close()
+	if err := w1.LeaseSectionFromMysql(openDB, "wuid_section", owner, time.Second*10); err != nil {
+		t.Fatal(err)
+	}
+	w2 := NewWUID("beta", dumb, WithSectionBits(16, 0))
+	defer w2.Close()
+	if err := w2.LeaseSectionFromMysql(openDB, "wuid_section", owner+"-beta", time.Second*10); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w1.LoadH28FromMysql(openDB, cfg.table); err != nil {
+		t.Fatal(err)
+	}
+	if err := w2.LoadH28FromMysql(openDB, cfg.table); err != nil {
+		t.Fatal(err)
+	}
+	if w1.Parse(w1.Next()).Section == w2.Parse(w2.Next()).Section {
+		t.Fatal("two owners should never share a section")
+	}
+}
@@ -0,0 +1,146 @@
+package wuid
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/edwingeng/wuid/internal"
+	"time"
+)
+
+type workerLeaser struct {
+	openDB OpenDB
+	table  string
+}
+
+func (l *workerLeaser) ClaimWorker(ctx context.Context, owner string, ttl time.Duration, horizon int64) (bool, int64, int64, int64, error) {
+	db, autoClose, err := l.openDB()
+	if err != nil {
+		return false, 0, 0, 0, err
+	}
+	defer func() {
+		if autoClose {
+			_ = db.Close()
+		}
+	}()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, 0, 0, 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// Every row is locked, so that no other claim can take the same worker ID in the meantime.
+	query := fmt.Sprintf("SELECT worker, token, horizon, expires_at <= NOW(3) FROM %s ORDER BY worker FOR UPDATE", l.table)
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return false, 0, 0, 0, err
+	}
+	var worker, token, since, next int64
+	var exists bool
+	for rows.Next() {
+		var expired bool
+		if err = rows.Scan(&worker, &token, &since, &expired); err != nil {
+			_ = rows.Close()
+			return false, 0, 0, 0, err
+		}
+		if worker != next {
+			break
+		}
+		if expired {
+			exists = true
+			break
+		}
+		next++
+	}
+	if err = rows.Close(); err != nil {
+		return false, 0, 0, 0, err
+	}
+	if err = rows.Err(); err != nil {
+		return false, 0, 0, 0, err
+	}
+	if !exists {
+		if next > 1023 {
+			return false, 0, 0, 0, nil
+		}
+		worker, token, since = next, 0, 0
+	}
+
+	token++
+	if since > horizon {
+		horizon = since
+	}
+	var stmt string
+	if exists {
+		stmt = fmt.Sprintf("UPDATE %s SET owner = ?, token = ?, horizon = ?, expires_at = NOW(3) + INTERVAL ? MICROSECOND WHERE worker = ?", l.table)
+	} else {
+		stmt = fmt.Sprintf("INSERT INTO %s (owner, token, horizon, expires_at, worker) VALUES (?, ?, ?, NOW(3) + INTERVAL ? MICROSECOND, ?)", l.table)
+	}
+	if _, err = tx.ExecContext(ctx, stmt, owner, token, horizon, ttl.Microseconds(), worker); err != nil {
+		return false, 0, 0, 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return false, 0, 0, 0, err
+	}
+	return true, worker, token, since, nil
+}
+
+func (l *workerLeaser) RenewWorker(ctx context.Context, worker, token, horizon int64, ttl time.Duration) (bool, error) {
+	db, autoClose, err := l.openDB()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if autoClose {
+			_ = db.Close()
+		}
+	}()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var current int64
+	query := fmt.Sprintf("SELECT token FROM %s WHERE worker = ? FOR UPDATE", l.table)
+	err = tx.QueryRowContext(ctx, query, worker).Scan(&current)
+	switch {
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		return false, err
+	case current != token:
+		return false, nil
+	}
+	stmt := fmt.Sprintf("UPDATE %s SET horizon = ?, expires_at = NOW(3) + INTERVAL ? MICROSECOND WHERE worker = ?", l.table)
+	if _, err = tx.ExecContext(ctx, stmt, horizon, ttl.Microseconds(), worker); err != nil {
+		return false, err
+	}
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// NewWorkerLeaser returns a WorkerLeaser that keeps the worker IDs in a MySQL table.
+func NewWorkerLeaser(openDB OpenDB, table string) WorkerLeaser {
+	return &workerLeaser{openDB: openDB, table: table}
+}
+
+type WorkerLeaser = internal.WorkerLeaser
+
+// LeaseWorkerFromMysql claims a free worker ID from MySQL on behalf of owner, and keeps renewing the lease
+// in the background until w is closed. The worker ID stays the same across renewals, and Next panics once
+// the lease expires. It only works with WithSnowflake, and fails if no worker ID is free.
+func (w *WUID) LeaseWorkerFromMysql(openDB OpenDB, table, owner string) error {
+	if len(table) == 0 {
+		return errors.New("table cannot be empty")
+	}
+	return w.w.LeaseWorker(NewWorkerLeaser(openDB, table), owner)
+}
@@ -0,0 +1,48 @@
+package wuid
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestWUID_LeaseWorkerFromMysql(t *testing.T) {
+	openDB := func() (*sql.DB, bool, error) {
+		db, err := connect()
+		return db, true, err
+	}
+
+	db, err := connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("DELETE FROM wuid_worker"); err != nil {
+		t.Fatal(err)
+	}
+
+	w1 := NewWUID("alpha", dumb, WithSnowflake(time.Now(), time.Second*10))
+	if err := w1.LeaseWorkerFromMysql(openDB, "wuid_worker", "pod-1"); err != nil {
+		t.Fatal(err)
+	}
+	w2 := NewWUID("alpha", dumb, WithSnowflake(time.Now(), time.Second*10))
+	defer w2.Close()
+	if err := w2.LeaseWorkerFromMysql(openDB, "wuid_worker", "pod-2"); err != nil {
+		t.Fatal(err)
+	}
+	if w1.Parse(w1.Next()).Worker == w2.Parse(w2.Next()).Worker {
+		t.Fatal("a leased worker ID should never be shared")
+	}
+	worker := w1.Parse(w1.Next()).Worker
+	last := w1.Next()
+	w1.Close()
+
+	w3 := NewWUID("alpha", dumb, WithSnowflake(time.Now(), time.Second*10))
+	defer w3.Close()
+	if err := w3.LeaseWorkerFromMysql(openDB, "wuid_worker", "pod-3"); err != nil {
+		t.Fatal(err)
+	}
+	if v := w3.Next(); v <= last || w3.Parse(v).Worker != worker {
+		t.Fatalf("the released worker ID is not reused as expected. v: %d, last: %d", v, last)
+	}
+}
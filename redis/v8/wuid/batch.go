@@ -0,0 +1,74 @@
+package wuid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/edwingeng/wuid/internal"
+	"github.com/go-redis/redis/v8"
+)
+
+// LoadH28FromRedisInBatch is like LoadH28FromRedis, but loads h28 for all of ws in one round trip by
+// pipelining the INCR commands. keys[i] is the key of ws[i]. The generators that fail are left untouched,
+// and the first error is returned after all of ws are tried.
+// Each generator resumes a leased block or falls back to its reserve just like LoadH28FromRedis.
+// The round trip is bounded by the longest RenewTimeout of ws.
+func LoadH28FromRedisInBatch(newClient NewClient, ws []*WUID, keys []string) error {
+	ctx1, cancel1 := context.WithTimeout(context.Background(), internal.MaxRenewTimeout(ws, unwrap))
+	defer cancel1()
+	return LoadH28FromRedisInBatchContext(ctx1, newClient, ws, keys)
+}
+
+// LoadH28FromRedisInBatchContext is like LoadH28FromRedisInBatch, but the round trip is bounded by ctx.
+func LoadH28FromRedisInBatchContext(ctx context.Context, newClient NewClient, ws []*WUID, keys []string) error {
+	if len(ws) != len(keys) {
+		return errors.New("ws and keys should have the same length")
+	}
+	for _, key := range keys {
+		if len(key) == 0 {
+			return errors.New("key cannot be empty")
+		}
+	}
+	if len(ws) == 0 {
+		return nil
+	}
+
+	cmds, err := incrInBatch(ctx, newClient, keys)
+	var firstErr error
+	for i, w := range ws {
+		w, key := w, keys[i]
+		var h28 int64
+		acqErr := err
+		if acqErr == nil {
+			h28, acqErr = cmds[i].Result()
+		}
+		if err := w.w.LoadAcquiredH28(ctx, NewSource(newClient, key), h28, acqErr); err != nil {
+			w.w.Warnf("<wuid> failed to load h28. name: %s, key: %s, reason: %+v", w.w.Name, key, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to load h28. name: %s, key: %s, reason: %w", w.w.Name, key, err)
+			}
+		}
+	}
+	return firstErr
+}
+
+// incrInBatch pipelines the INCR commands of keys.
+func incrInBatch(ctx context.Context, newClient NewClient, keys []string) ([]*redis.IntCmd, error) {
+	client, autoClose, err := newClient()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if autoClose {
+			_ = client.Close()
+		}
+	}()
+
+	pipe := client.Pipeline()
+	cmds := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Incr(ctx, key)
+	}
+	_, _ = pipe.Exec(ctx)
+	return cmds, nil
+}
@@ -0,0 +1,62 @@
+package wuid
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"testing"
+)
+
+func TestLoadH28FromRedisInBatch(t *testing.T) {
+	client := connect()
+	newClient := func() (redis.UniversalClient, bool, error) {
+		return client, false, nil
+	}
+
+	ws := []*WUID{NewWUID("alpha", dumb), NewWUID("beta", dumb)}
+	keys := []string{"wuid:batch:alpha", "wuid:batch:beta"}
+	if err := LoadH28FromRedisInBatch(newClient, ws, keys); err != nil {
+		t.Fatal(err)
+	}
+	for i, w := range ws {
+		n, err := client.Get(context.Background(), keys[i]).Int64()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w.Parse(w.Next()).H28 != n {
+			t.Fatalf("h28 of %s does not match its key", w.w.Name)
+		}
+		if w.w.Renew == nil {
+			t.Fatal(`w.w.Renew == nil`)
+		}
+	}
+
+	if err := LoadH28FromRedisInBatch(newClient, ws, keys[:1]); err == nil {
+		t.Fatal("LoadH28FromRedisInBatch should fail when the lengths differ")
+	}
+	if err := LoadH28FromRedisInBatch(newClient, ws, []string{"wuid:batch:alpha", ""}); err == nil {
+		t.Fatal("LoadH28FromRedisInBatch should fail when a key is empty")
+	}
+}
+
+func TestRegistry_Preload(t *testing.T) {
+	client := connect()
+	newClient := func() (redis.UniversalClient, bool, error) {
+		return client, false, nil
+	}
+
+	r := NewRegistry(newClient, "wuid:%s", dumb)
+	defer r.Close()
+	if err := r.Preload("alpha", "beta", "alpha"); err != nil {
+		t.Fatal(err)
+	}
+	if stats := r.Stats(); stats.NumGenerators != 2 {
+		t.Fatalf("Preload does not work as expected. stats: %+v", stats)
+	}
+	w, err := r.Get("beta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := client.Get(context.Background(), "wuid:beta").Int64(); err != nil || n != w.Parse(w.Next()).H28 {
+		t.Fatal("the key of beta is not made by the template")
+	}
+}
@@ -0,0 +1,51 @@
+package wuid
+
+import (
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestWUID_LeaseBlocksFromRedis(t *testing.T) {
+	newClient := func() (redis.UniversalClient, bool, error) {
+		return connect(), true, nil
+	}
+
+	key := fmt.Sprintf("wuid-block-%d", rand.Int63())
+	w1 := NewWUID("alpha", dumb)
+	if err := w1.LeaseBlocksFromRedis(newClient, key, "pod-1", time.Second*10); err != nil {
+		t.Fatal(err)
+	}
+	if err := w1.LoadH28FromRedis(newClient, cfg.key); err != nil {
+		t.Fatal(err)
+	}
+	w1.Next()
+	last := w1.Next()
+	w1.Close()
+
+	w2 := NewWUID("alpha", dumb)
+	defer w2.Close()
+	if err := w2.LeaseBlocksFromRedis(newClient, key, "pod-2", time.Second*10); err != nil {
+		t.Fatal(err)
+	}
+	if err := w2.LoadH28FromRedis(newClient, cfg.key); err != nil {
+		t.Fatal(err)
+	}
+	if v := w2.Next(); v != last+1 {
+		t.Fatalf("the block is not resumed. v: %d, last: %d", v, last)
+	}
+
+	w3 := NewWUID("alpha", dumb)
+	defer w3.Close()
+	if err := w3.LeaseBlocksFromRedis(newClient, key, "pod-3", time.Second*10); err != nil {
+		t.Fatal(err)
+	}
+	if err := w3.LoadH28FromRedis(newClient, cfg.key); err != nil {
+		t.Fatal(err)
+	}
+	if w3.Parse(w3.Next()).H28 == w2.Parse(w2.Next()).H28 {
+		t.Fatal("a leased block should never be shared")
+	}
+}
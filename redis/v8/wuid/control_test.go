@@ -0,0 +1,49 @@
+package wuid
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestWUID_WatchControl(t *testing.T) {
+	newClient := func() (redis.UniversalClient, bool, error) {
+		return connect(), true, nil
+	}
+
+	ch := NewControlChannel(newClient, fmt.Sprintf("wuid-control-%d", rand.Int63()))
+	w := NewWUID("alpha", dumb)
+	defer w.Close()
+	if err := w.LoadH28FromRedis(newClient, cfg.key); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WatchControl(ch, "pod-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if err := ch.Send(ctx, CommandPause); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(ControlPollInterval * 2)
+	for time.Now().Before(deadline) {
+		if _, err := w.TryNext(); err != nil {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if _, err := w.TryNext(); err == nil {
+		t.Fatal("w should have been paused")
+	}
+
+	acks, err := ch.Acks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(acks) != 1 || acks[0].Owner != "pod-1" || !acks[0].Paused {
+		t.Fatalf("unexpected acks: %+v", acks)
+	}
+}
@@ -0,0 +1,28 @@
+package wuid
+
+import (
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func TestWithProvenance(t *testing.T) {
+	newClient := func() (redis.UniversalClient, bool, error) {
+		return connect(), true, nil
+	}
+
+	store := NewProvenanceStore(newClient, fmt.Sprintf("wuid-provenance-%d", rand.Int63()))
+	w := NewWUID("alpha", dumb, WithProvenance(store, "v1.2.3"))
+	if err := w.LoadH28FromRedis(newClient, cfg.key); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := w.Provenance(w.Next())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Name != "alpha" || rec.PID != os.Getpid() || rec.Version != "v1.2.3" {
+		t.Fatalf("unexpected record: %+v", rec)
+	}
+}
@@ -0,0 +1,63 @@
+package wuid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+var leaseSectionScript = redis.NewScript(`
+local owner = redis.call('GET', KEYS[1])
+if owner and owner ~= ARGV[1] or not owner and ARGV[4] == '0' then
+	return -1
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+local h28 = tonumber(redis.call('GET', KEYS[2]) or '0')
+if tonumber(ARGV[3]) > h28 then
+	redis.call('SET', KEYS[2], ARGV[3])
+end
+return h28
+`)
+
+type sectionLeaser struct {
+	newClient NewClient
+	key       string
+}
+
+func (l *sectionLeaser) LeaseSection(ctx context.Context, section int64, owner string, ttl time.Duration, h28 int64, claim bool) (bool, int64, error) {
+	client, autoClose, err := l.newClient()
+	if err != nil {
+		return false, 0, err
+	}
+	defer func() {
+		if autoClose {
+			_ = client.Close()
+		}
+	}()
+
+	keys := []string{
+		fmt.Sprintf("%s:{%d}", l.key, section),
+		fmt.Sprintf("%s:{%d}:h28", l.key, section),
+	}
+	maxH28, err := leaseSectionScript.Run(ctx, client, keys, owner, ttl.Milliseconds(), h28, claim).Int64()
+	if err != nil {
+		return false, 0, err
+	}
+	if maxH28 < 0 {
+		return false, 0, nil
+	}
+	return true, maxH28, nil
+}
+
+// LeaseSectionFromRedis joins the section held by owner, which usually identifies a cluster, or claims a free one,
+// and keeps renewing the lease in the background until w is closed. Next panics if the lease is lost.
+// The width of the section field is specified by WithSectionBits, and defaults to 3 bits.
+// The section must be leased before h28 is loaded.
+func (w *WUID) LeaseSectionFromRedis(newClient NewClient, key, owner string, ttl time.Duration) error {
+	if len(key) == 0 {
+		return errors.New("key cannot be empty")
+	}
+	return w.w.LeaseSection(&sectionLeaser{newClient: newClient, key: key}, owner, ttl)
+}
@@ -0,0 +1,152 @@
+package wuid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/edwingeng/wuid/internal"
+	"github.com/go-redis/redis"
+	"time"
+)
+
+// Each field of the hash is the h28 of a block, and its value is "token checkpoint expiresAt owner".
+const blockScriptPrelude = `
+if redis.replicate_commands then
+	redis.replicate_commands()
+end
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+`
+
+var claimBlockScript = redis.NewScript(blockScriptPrelude + `
+local found, block
+local all = redis.call('HGETALL', KEYS[1])
+for i = 1, #all, 2 do
+	local h28 = tonumber(all[i])
+	local token, checkpoint, expiresAt, owner = string.match(all[i+1], '^(%d+) (%d+) (%d+) (.*)$')
+	local b = {token = tonumber(token), checkpoint = tonumber(checkpoint)}
+	if (owner == ARGV[1] or tonumber(expiresAt) <= now) and b.checkpoint < tonumber(ARGV[3]) and (not found or h28 < found) then
+		found, block = h28, b
+	end
+end
+if not found then
+	return false
+end
+block.token = block.token + 1
+local v = string.format('%d %d %d %s', block.token, block.checkpoint, now + tonumber(ARGV[2]), ARGV[1])
+redis.call('HSET', KEYS[1], found, v)
+return {found, block.checkpoint, block.token}
+`)
+
+var saveBlockScript = redis.NewScript(blockScriptPrelude + `
+local v = redis.call('HGET', KEYS[1], ARGV[2])
+if v then
+	if string.match(v, '^(%d+) ') ~= ARGV[3] then
+		return 0
+	end
+elseif ARGV[3] ~= '1' then
+	return 0
+end
+v = string.format('%s %s %d %s', ARGV[3], ARGV[4], now + tonumber(ARGV[5]), ARGV[1])
+redis.call('HSET', KEYS[1], ARGV[2], v)
+return 1
+`)
+
+var dropBlockScript = redis.NewScript(`
+local v = redis.call('HGET', KEYS[1], ARGV[1])
+if v and string.match(v, '^(%d+) ') == ARGV[2] then
+	redis.call('HDEL', KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+type blockLeaser struct {
+	newClient NewClient
+	key       string
+}
+
+func (l *blockLeaser) ClaimBlock(ctx context.Context, owner string, ttl time.Duration, below int64) (bool, int64, int64, int64, error) {
+	client, autoClose, err := l.newClient()
+	if err != nil {
+		return false, 0, 0, 0, err
+	}
+	defer func() {
+		if autoClose {
+			_ = client.Close()
+		}
+	}()
+
+	if err := ctx.Err(); err != nil {
+		return false, 0, 0, 0, err
+	}
+	v, err := claimBlockScript.Run(client, []string{l.key}, owner, ttl.Milliseconds(), below).Result()
+	switch {
+	case err == redis.Nil:
+		return false, 0, 0, 0, nil
+	case err != nil:
+		return false, 0, 0, 0, err
+	}
+	a, ok := v.([]interface{})
+	if !ok || len(a) != 3 {
+		return false, 0, 0, 0, fmt.Errorf("unexpected reply: %v", v)
+	}
+	h28, _ := a[0].(int64)
+	checkpoint, _ := a[1].(int64)
+	token, _ := a[2].(int64)
+	return true, h28, checkpoint, token, nil
+}
+
+func (l *blockLeaser) SaveBlock(ctx context.Context, owner string, h28, token, checkpoint int64, ttl time.Duration) (bool, error) {
+	client, autoClose, err := l.newClient()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if autoClose {
+			_ = client.Close()
+		}
+	}()
+
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	ok, err := saveBlockScript.Run(client, []string{l.key}, owner, h28, token, checkpoint, ttl.Milliseconds()).Int()
+	return ok == 1, err
+}
+
+func (l *blockLeaser) DropBlock(ctx context.Context, h28, token int64) error {
+	client, autoClose, err := l.newClient()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if autoClose {
+			_ = client.Close()
+		}
+	}()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return dropBlockScript.Run(client, []string{l.key}, h28, token).Err()
+}
+
+// NewBlockLeaser returns a BlockLeaser that keeps the blocks in a Redis hash.
+// go-redis v6 does not support context, so ctx is only checked before each round trip.
+func NewBlockLeaser(newClient NewClient, key string) BlockLeaser {
+	return &blockLeaser{newClient: newClient, key: key}
+}
+
+type BlockLeaser = internal.BlockLeaser
+
+// LeaseBlocksFromRedis makes w record in Redis the checkpoint of every h28 block it loads, on behalf of
+// owner, which should survive restarts, e.g. a pod name. When h28 is loaded for the first time, w resumes
+// the unused tail of a block whose lease has expired or is held by owner, instead of acquiring a new one.
+// Next never passes the recorded checkpoint, so two holders of a block can never overlap. Close records
+// the exact position and releases the lease. The blocks must be leased before h28 is loaded.
+func (w *WUID) LeaseBlocksFromRedis(newClient NewClient, key, owner string, ttl time.Duration) error {
+	if len(key) == 0 {
+		return errors.New("key cannot be empty")
+	}
+	return w.w.LeaseBlocks(NewBlockLeaser(newClient, key), owner, ttl)
+}
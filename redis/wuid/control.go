@@ -0,0 +1,170 @@
+package wuid
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-redis/redis"
+	"net"
+	"strconv"
+	"time"
+)
+
+type controlChannel struct {
+	newClient NewClient
+	key       string
+}
+
+// NewControlChannel returns a ControlChannel that keeps the state in the Redis hash key, the
+// acknowledgements in key+":acks", and notifies the watchers through the pub/sub channel key+":notify".
+// go-redis v6 does not support context, so ctx is only checked before each round trip, and Wait
+// only honors the deadline of ctx.
+func NewControlChannel(newClient NewClient, key string) ControlChannel {
+	return &controlChannel{newClient: newClient, key: key}
+}
+
+func (c *controlChannel) Send(ctx context.Context, cmd Command) error {
+	client, autoClose, err := c.newClient()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if autoClose {
+			_ = client.Close()
+		}
+	}()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	switch cmd {
+	case CommandRenew:
+		err = client.HIncrBy(c.key, "renewSeq", 1).Err()
+	case CommandPause:
+		err = client.HSet(c.key, "paused", 1).Err()
+	case CommandResume:
+		err = client.HSet(c.key, "paused", 0).Err()
+	default:
+		err = errors.New("unknown command")
+	}
+	if err != nil {
+		return err
+	}
+	return client.Publish(c.key+":notify", cmd.String()).Err()
+}
+
+func (c *controlChannel) State(ctx context.Context) (ControlState, error) {
+	client, autoClose, err := c.newClient()
+	if err != nil {
+		return ControlState{}, err
+	}
+	defer func() {
+		if autoClose {
+			_ = client.Close()
+		}
+	}()
+
+	if err := ctx.Err(); err != nil {
+		return ControlState{}, err
+	}
+	m, err := client.HGetAll(c.key).Result()
+	if err != nil {
+		return ControlState{}, err
+	}
+	var state ControlState
+	if v, ok := m["renewSeq"]; ok {
+		if state.RenewSeq, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return ControlState{}, err
+		}
+	}
+	state.Paused = m["paused"] == "1"
+	return state, nil
+}
+
+func (c *controlChannel) Wait(ctx context.Context) error {
+	client, autoClose, err := c.newClient()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if autoClose {
+			_ = client.Close()
+		}
+	}()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return errors.New("ctx must have a deadline")
+	}
+	pubSub := client.Subscribe(c.key + ":notify")
+	defer func() {
+		_ = pubSub.Close()
+	}()
+	for {
+		timeout := time.Until(deadline)
+		if timeout <= 0 || ctx.Err() != nil {
+			return nil
+		}
+		msg, err := pubSub.ReceiveTimeout(timeout)
+		if err != nil {
+			if e, ok := err.(net.Error); ok && e.Timeout() {
+				return nil
+			}
+			return err
+		}
+		if _, ok := msg.(*redis.Message); ok {
+			return nil
+		}
+	}
+}
+
+func (c *controlChannel) Ack(ctx context.Context, ack ControlAck) error {
+	data, err := json.Marshal(ack)
+	if err != nil {
+		return err
+	}
+
+	client, autoClose, err := c.newClient()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if autoClose {
+			_ = client.Close()
+		}
+	}()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return client.HSet(c.key+":acks", ack.Owner, data).Err()
+}
+
+func (c *controlChannel) Acks(ctx context.Context) ([]ControlAck, error) {
+	client, autoClose, err := c.newClient()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if autoClose {
+			_ = client.Close()
+		}
+	}()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m, err := client.HGetAll(c.key + ":acks").Result()
+	if err != nil {
+		return nil, err
+	}
+	acks := make([]ControlAck, 0, len(m))
+	for _, v := range m {
+		var ack ControlAck
+		if err := json.Unmarshal([]byte(v), &ack); err != nil {
+			return nil, err
+		}
+		acks = append(acks, ack)
+	}
+	return acks, nil
+}
@@ -0,0 +1,67 @@
+package wuid
+
+import (
+	"context"
+	"errors"
+	"github.com/edwingeng/wuid/internal"
+	"github.com/go-redis/redis"
+)
+
+var nextInPartitionScript = redis.NewScript(`
+local n = tonumber(ARGV[1])
+local c = tonumber(redis.call('GET', KEYS[1]) or '0')
+local v = c + (tonumber(ARGV[2]) - c - 1) % n + 1
+redis.call('SET', KEYS[1], v)
+return v
+`)
+
+type partitionSource struct {
+	newClient NewClient
+	key       string
+	n         int64
+	i         int64
+}
+
+// NewPartitionSource returns an H28Source that only hands out numbers congruent to i modulo n,
+// i.e. the i-th partition of n. Each partition should be kept by an independent Redis.
+// n must be in between [1, 1024], and i must be in between [0, n).
+func NewPartitionSource(newClient NewClient, key string, n, i int64) H28Source {
+	internal.CheckPartition(n, i)
+	return &partitionSource{newClient: newClient, key: key, n: n, i: i}
+}
+
+func (s *partitionSource) Acquire(ctx context.Context) (int64, error) {
+	if len(s.key) == 0 {
+		return 0, errors.New("key cannot be empty")
+	}
+
+	client, autoClose, err := s.newClient()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if autoClose {
+			_ = client.Close()
+		}
+	}()
+
+	// go-redis v6 does not support context.
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return nextInPartitionScript.Run(client, []string{s.key}, s.n, s.i).Int64()
+}
+
+// Partition returns n and i, so that the generators verify the residue of each h28.
+func (s *partitionSource) Partition() (n, i int64) {
+	return s.n, s.i
+}
+
+// Check is the same as the Check of the source returned by NewSource.
+func (s *partitionSource) Check(ctx context.Context) (int64, error) {
+	return (&source{newClient: s.newClient, key: s.key}).Check(ctx)
+}
+
+func (s *partitionSource) String() string {
+	return "redis:" + s.key
+}
@@ -0,0 +1,33 @@
+package wuid
+
+import (
+	"context"
+	"github.com/go-redis/redis"
+	"testing"
+)
+
+func TestNewPartitionSource(t *testing.T) {
+	client := connect()
+	newClient := func() (redis.UniversalClient, bool, error) {
+		return client, false, nil
+	}
+
+	key := cfg.key + ":partition"
+	if err := client.Set(key, 7, 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	src := NewPartitionSource(newClient, key, 3, 2)
+	for _, expected := range []int64{8, 11, 14} {
+		if h28, err := src.Acquire(context.Background()); err != nil || h28 != expected {
+			t.Fatalf("h28 should be %d. h28: %d, err: %v", expected, h28, err)
+		}
+	}
+
+	func() {
+		defer func() {
+			_ = recover()
+		}()
+		NewPartitionSource(newClient, key, 3, 3)
+		t.Fatal("NewPartitionSource should have panicked")
+	}()
+}
@@ -0,0 +1,75 @@
+package wuid
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+type provenanceStore struct {
+	newClient NewClient
+	key       string
+}
+
+// NewProvenanceStore returns a ProvenanceStore that keeps the records of each h28 in a Redis hash,
+// whose key is made of key and h28, e.g. "wuid:provenance:{42}".
+// go-redis v6 does not support context, so ctx is only checked before each round trip.
+func NewProvenanceStore(newClient NewClient, key string) ProvenanceStore {
+	return &provenanceStore{newClient: newClient, key: key}
+}
+
+func (s *provenanceStore) Record(ctx context.Context, h28 int64, rec ProvenanceRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	client, autoClose, err := s.newClient()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if autoClose {
+			_ = client.Close()
+		}
+	}()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return client.HSet(fmt.Sprintf("%s:{%d}", s.key, h28), strconv.FormatInt(rec.Start, 10), data).Err()
+}
+
+func (s *provenanceStore) Lookup(ctx context.Context, h28 int64) ([]ProvenanceRecord, error) {
+	client, autoClose, err := s.newClient()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if autoClose {
+			_ = client.Close()
+		}
+	}()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m, err := client.HGetAll(fmt.Sprintf("%s:{%d}", s.key, h28)).Result()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]ProvenanceRecord, 0, len(m))
+	for _, v := range m {
+		var rec ProvenanceRecord
+		if err := json.Unmarshal([]byte(v), &rec); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Start < records[j].Start
+	})
+	return records, nil
+}
@@ -0,0 +1,65 @@
+package wuid
+
+import (
+	"fmt"
+	"github.com/edwingeng/slog"
+	"github.com/edwingeng/wuid/internal"
+)
+
+// Registry creates generators by name on demand. All the generators share the same Redis configuration,
+// and the key of each generator is made by keyTemplate, e.g. "wuid:%s".
+type Registry struct {
+	r internal.Registry[*WUID]
+}
+
+// NewRegistry creates a new Registry. opts are applied to each generator.
+func NewRegistry(newClient NewClient, keyTemplate string, logger slog.Logger, opts ...Option) *Registry {
+	reg := &Registry{}
+	reg.r.New = func(name string) (*WUID, error) {
+		w := NewWUID(name, logger, opts...)
+		if err := w.LoadH28FromRedis(newClient, fmt.Sprintf(keyTemplate, name)); err != nil {
+			return nil, err
+		}
+		return w, nil
+	}
+	reg.r.NewInBatch = func(names []string) ([]*WUID, error) {
+		ws := make([]*WUID, len(names))
+		keys := make([]string, len(names))
+		for i, name := range names {
+			ws[i] = NewWUID(name, logger, opts...)
+			keys[i] = fmt.Sprintf(keyTemplate, name)
+		}
+		if err := LoadH28FromRedisInBatch(newClient, ws, keys); err != nil {
+			for _, w := range ws {
+				w.Close()
+			}
+			return nil, err
+		}
+		return ws, nil
+	}
+	reg.r.Unwrap = unwrap
+	return reg
+}
+
+// Get returns the generator named name. The generator is created and loaded on the first call.
+func (r *Registry) Get(name string) (*WUID, error) {
+	return r.r.Get(name)
+}
+
+// Preload creates and loads the generators of names in one round trip, so that the following Get
+// calls on them return immediately.
+func (r *Registry) Preload(names ...string) error {
+	return r.r.Preload(names...)
+}
+
+type RegistryStats = internal.RegistryStats
+
+// Stats returns the aggregate statistics of all the generators.
+func (r *Registry) Stats() RegistryStats {
+	return r.r.Stats()
+}
+
+// Close closes all the generators. Get fails after Close.
+func (r *Registry) Close() {
+	r.r.Close()
+}
@@ -0,0 +1,40 @@
+package wuid
+
+import (
+	"github.com/go-redis/redis"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	client := connect()
+	newClient := func() (redis.UniversalClient, bool, error) {
+		return client, false, nil
+	}
+
+	r := NewRegistry(newClient, "wuid:%s", dumb)
+	w1, err := r.Get("alpha")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w2, err := r.Get("beta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w3, _ := r.Get("alpha"); w3 != w1 || w2 == w1 {
+		t.Fatal("Get does not work as expected")
+	}
+	if err := w1.RenewNow(); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := client.Get("wuid:beta").Int64(); err != nil || n != w2.Parse(w2.Next()).H28 {
+		t.Fatal("the key of beta is not made by the template")
+	}
+	if stats := r.Stats(); stats.NumGenerators != 2 {
+		t.Fatalf("Stats does not work as expected. stats: %+v", stats)
+	}
+
+	r.Close()
+	if _, err := r.Get("gamma"); err == nil {
+		t.Fatal("Get should fail after Close")
+	}
+}
@@ -0,0 +1,41 @@
+package wuid
+
+import (
+	"fmt"
+	"github.com/go-redis/redis"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestWUID_LeaseSectionFromRedis(t *testing.T) {
+	newClient := func() (redis.UniversalClient, bool, error) {
+		return connect(), true, nil
+	}
+
+	key := fmt.Sprintf("wuid-section-%d", rand.Int63())
+	w1 := NewWUID("alpha", dumb, WithSectionBits(1, 0))
+	defer w1.Close()
+	if err := w1.LeaseSectionFromRedis(newClient, key, "alpha", time.Second*10); err != nil {
+		t.Fatal(err)
+	}
+	w2 := NewWUID("beta", dumb, WithSectionBits(1, 0))
+	defer w2.Close()
+	if err := w2.LeaseSectionFromRedis(newClient, key, "beta", time.Second*10); err != nil {
+		t.Fatal(err)
+	}
+	w3 := NewWUID("gamma", dumb, WithSectionBits(1, 0))
+	if err := w3.LeaseSectionFromRedis(newClient, key, "gamma", time.Second*10); err == nil {
+		t.Fatal("LeaseSectionFromRedis should fail when there is no free section")
+	}
+
+	if err := w1.LoadH28FromRedis(newClient, cfg.key); err != nil {
+		t.Fatal(err)
+	}
+	if err := w2.LoadH28FromRedis(newClient, cfg.key); err != nil {
+		t.Fatal(err)
+	}
+	if w1.Parse(w1.Next()).Section == w2.Parse(w2.Next()).Section {
+		t.Fatal("two owners should never share a section")
+	}
+}
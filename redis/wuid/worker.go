@@ -0,0 +1,118 @@
+package wuid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/edwingeng/wuid/internal"
+	"github.com/go-redis/redis"
+	"time"
+)
+
+// Each field of the hash is a worker ID, and its value is "token horizon expiresAt owner".
+var claimWorkerScript = redis.NewScript(blockScriptPrelude + `
+local leases = {}
+local all = redis.call('HGETALL', KEYS[1])
+for i = 1, #all, 2 do
+	leases[tonumber(all[i])] = all[i+1]
+end
+for worker = 0, 1023 do
+	local token, since, expiresAt = 0, 0, 0
+	if leases[worker] then
+		local a, b, c = string.match(leases[worker], '^(%d+) (-?%d+) (%d+) ')
+		token, since, expiresAt = tonumber(a), tonumber(b), tonumber(c)
+	end
+	if expiresAt <= now then
+		local v = string.format('%d %d %d %s', token + 1, math.max(since, tonumber(ARGV[3])), now + tonumber(ARGV[2]), ARGV[1])
+		redis.call('HSET', KEYS[1], worker, v)
+		return {worker, token + 1, since}
+	end
+end
+return false
+`)
+
+var renewWorkerScript = redis.NewScript(blockScriptPrelude + `
+local v = redis.call('HGET', KEYS[1], ARGV[1])
+if not v then
+	return 0
+end
+local token, owner = string.match(v, '^(%d+) %-?%d+ %d+ (.*)$')
+if token ~= ARGV[2] then
+	return 0
+end
+v = string.format('%s %s %d %s', token, ARGV[3], now + tonumber(ARGV[4]), owner)
+redis.call('HSET', KEYS[1], ARGV[1], v)
+return 1
+`)
+
+type workerLeaser struct {
+	newClient NewClient
+	key       string
+}
+
+func (l *workerLeaser) ClaimWorker(ctx context.Context, owner string, ttl time.Duration, horizon int64) (bool, int64, int64, int64, error) {
+	client, autoClose, err := l.newClient()
+	if err != nil {
+		return false, 0, 0, 0, err
+	}
+	defer func() {
+		if autoClose {
+			_ = client.Close()
+		}
+	}()
+
+	if err := ctx.Err(); err != nil {
+		return false, 0, 0, 0, err
+	}
+	v, err := claimWorkerScript.Run(client, []string{l.key}, owner, ttl.Milliseconds(), horizon).Result()
+	switch {
+	case err == redis.Nil:
+		return false, 0, 0, 0, nil
+	case err != nil:
+		return false, 0, 0, 0, err
+	}
+	a, ok := v.([]interface{})
+	if !ok || len(a) != 3 {
+		return false, 0, 0, 0, fmt.Errorf("unexpected reply: %v", v)
+	}
+	worker, _ := a[0].(int64)
+	token, _ := a[1].(int64)
+	since, _ := a[2].(int64)
+	return true, worker, token, since, nil
+}
+
+func (l *workerLeaser) RenewWorker(ctx context.Context, worker, token, horizon int64, ttl time.Duration) (bool, error) {
+	client, autoClose, err := l.newClient()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if autoClose {
+			_ = client.Close()
+		}
+	}()
+
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	ok, err := renewWorkerScript.Run(client, []string{l.key}, worker, token, horizon, ttl.Milliseconds()).Int()
+	return ok == 1, err
+}
+
+// NewWorkerLeaser returns a WorkerLeaser that keeps the worker IDs in a Redis hash.
+// go-redis v6 does not support context, so ctx is only checked before each round trip.
+func NewWorkerLeaser(newClient NewClient, key string) WorkerLeaser {
+	return &workerLeaser{newClient: newClient, key: key}
+}
+
+type WorkerLeaser = internal.WorkerLeaser
+
+// LeaseWorkerFromRedis claims a free worker ID from Redis on behalf of owner, and keeps renewing the lease
+// in the background until w is closed. The worker ID stays the same across renewals, and Next panics once
+// the lease expires. It only works with WithSnowflake, and fails if no worker ID is free.
+func (w *WUID) LeaseWorkerFromRedis(newClient NewClient, key, owner string) error {
+	if len(key) == 0 {
+		return errors.New("key cannot be empty")
+	}
+	return w.w.LeaseWorker(NewWorkerLeaser(newClient, key), owner)
+}
@@ -0,0 +1,41 @@
+package wuid
+
+import (
+	"fmt"
+	"github.com/go-redis/redis"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestWUID_LeaseWorkerFromRedis(t *testing.T) {
+	newClient := func() (redis.UniversalClient, bool, error) {
+		return connect(), true, nil
+	}
+
+	key := fmt.Sprintf("wuid-worker-%d", rand.Int63())
+	w1 := NewWUID("alpha", dumb, WithSnowflake(time.Now(), time.Second*10))
+	if err := w1.LeaseWorkerFromRedis(newClient, key, "pod-1"); err != nil {
+		t.Fatal(err)
+	}
+	w2 := NewWUID("alpha", dumb, WithSnowflake(time.Now(), time.Second*10))
+	defer w2.Close()
+	if err := w2.LeaseWorkerFromRedis(newClient, key, "pod-2"); err != nil {
+		t.Fatal(err)
+	}
+	if w1.Parse(w1.Next()).Worker == w2.Parse(w2.Next()).Worker {
+		t.Fatal("a leased worker ID should never be shared")
+	}
+	worker := w1.Parse(w1.Next()).Worker
+	last := w1.Next()
+	w1.Close()
+
+	w3 := NewWUID("alpha", dumb, WithSnowflake(time.Now(), time.Second*10))
+	defer w3.Close()
+	if err := w3.LeaseWorkerFromRedis(newClient, key, "pod-3"); err != nil {
+		t.Fatal(err)
+	}
+	if v := w3.Next(); v <= last || w3.Parse(v).Worker != worker {
+		t.Fatalf("the released worker ID is not reused as expected. v: %d, last: %d", v, last)
+	}
+}
@@ -0,0 +1,18 @@
+package wuid
+
+import (
+	"github.com/edwingeng/slog"
+	"github.com/edwingeng/wuid/internal"
+)
+
+type FailoverSource = internal.FailoverSource
+type FailoverStats = internal.FailoverStats
+type FailoverSourceStats = internal.FailoverSourceStats
+
+// NewFailoverSource creates an H28Source that tries sources in order until one of them succeeds,
+// e.g. Redis first and then MySQL. The h28 space is partitioned among the sources: a number h
+// acquired from the i-th source of n becomes h*n+i, so the numbers of different sources never
+// collide. Failovers are logged and counted in Stats.
+func NewFailoverSource(logger slog.Logger, sources ...H28Source) *FailoverSource {
+	return internal.NewFailoverSource(logger, sources...)
+}
@@ -0,0 +1,19 @@
+package wuid
+
+import (
+	"github.com/edwingeng/slog"
+	"github.com/edwingeng/wuid/internal"
+)
+
+type PartitionedSource = internal.PartitionedSource
+
+// Partitioner is implemented by the data sources that only hand out numbers congruent to i modulo n.
+// The residue of each h28 loaded from them is verified.
+type Partitioner = internal.Partitioner
+
+// NewPartitionedSource creates an H28Source that acquires h28 from whichever of sources is reachable.
+// sources[i] must only hand out numbers congruent to i modulo len(sources), e.g. the NewPartitionSource
+// of the Redis, MySQL and MongoDB packages, which is verified on each acquisition.
+func NewPartitionedSource(logger slog.Logger, sources ...H28Source) *PartitionedSource {
+	return internal.NewPartitionedSource(logger, sources...)
+}
@@ -0,0 +1,22 @@
+package wuid
+
+import (
+	"github.com/edwingeng/slog"
+	"github.com/edwingeng/wuid/internal"
+)
+
+type H28Advancer = internal.H28Advancer
+type H28Register = internal.H28Register
+type QuorumSource = internal.QuorumSource
+
+// QuorumMaxAttempts is how many times a QuorumSource tries before it gives up on the contention.
+const QuorumMaxAttempts = internal.QuorumMaxAttempts
+
+// NewQuorumSource creates an H28Source that keeps the same counter in several independent sources,
+// e.g. the NewSource of the Redis, MySQL and MongoDB packages. Acquire reads the counters of a majority,
+// takes the maximum plus one, and compares-and-swaps the counters to it. It succeeds only if a majority
+// are swapped, and retries otherwise, so concurrent acquisitions never get the same h28, and a rollback
+// of a minority of the sources is outvoted.
+func NewQuorumSource(logger slog.Logger, sources ...H28Register) *QuorumSource {
+	return internal.NewQuorumSource(logger, sources...)
+}
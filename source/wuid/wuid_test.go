@@ -0,0 +1,98 @@
+package wuid
+
+import (
+	"context"
+	"errors"
+	"github.com/edwingeng/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var (
+	dumb = slog.NewDumbLogger()
+)
+
+func TestWUID_LoadH28FromSource_Error(t *testing.T) {
+	w := NewWUID("alpha", dumb)
+	if err := w.LoadH28FromSource(nil); err == nil {
+		t.Fatal("LoadH28FromSource should fail when src is nil")
+	}
+
+	err := w.LoadH28FromSource(H28SourceFunc(func(ctx context.Context) (int64, error) {
+		return 0, errors.New("foo")
+	}))
+	if err == nil {
+		t.Fatal("LoadH28FromSource should fail when src returns an error")
+	}
+
+	err = w.LoadH28FromSource(H28SourceFunc(func(ctx context.Context) (int64, error) {
+		return 0, nil
+	}))
+	if err == nil {
+		t.Fatal("LoadH28FromSource should fail when src returns an invalid h28")
+	}
+}
+
+func TestWUID_LoadH28FromSource(t *testing.T) {
+	var h28 int64
+	src := H28SourceFunc(func(ctx context.Context) (int64, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			return 0, errors.New("ctx should have a deadline")
+		}
+		return atomic.AddInt64(&h28, 1), nil
+	})
+
+	w := NewWUID("alpha", dumb, WithSection(1))
+	if err := w.LoadH28FromSource(src); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < 100; i++ {
+		if err := w.RenewNow(); err != nil {
+			t.Fatal(err)
+		}
+		parsed := w.Parse(w.Next())
+		if parsed.H28 != int64(i)+1 || parsed.Section != 1 {
+			t.Fatalf("the source does not work as expected. i: %d, parsed: %+v", i, parsed)
+		}
+	}
+}
+
+type oneWorkerLeaser struct {
+	token int64
+}
+
+func (l *oneWorkerLeaser) ClaimWorker(ctx context.Context, owner string, ttl time.Duration, horizon int64) (bool, int64, int64, int64, error) {
+	if l.token != 0 {
+		return false, 0, 0, 0, nil
+	}
+	l.token = 1
+	return true, 7, l.token, 0, nil
+}
+
+func (l *oneWorkerLeaser) RenewWorker(ctx context.Context, worker, token, horizon int64, ttl time.Duration) (bool, error) {
+	return token == l.token, nil
+}
+
+func TestWUID_LeaseWorker(t *testing.T) {
+	src := H28SourceFunc(func(ctx context.Context) (int64, error) {
+		return 1025, nil
+	})
+
+	w := NewWUID("alpha", dumb, WithSnowflake(time.Now().Add(-time.Hour), time.Minute))
+	defer w.Close()
+	if err := w.LoadH28FromSource(src); err == nil {
+		t.Fatal("LoadH28FromSource should fail in the snowflake mode")
+	}
+	leaser := &oneWorkerLeaser{}
+	if err := w.LeaseWorker(leaser, "alpha"); err != nil {
+		t.Fatal(err)
+	}
+	if parsed := w.Parse(w.Next()); parsed.Worker != 7 {
+		t.Fatalf("the worker ID is wrong. parsed: %+v", parsed)
+	}
+	w2 := NewWUID("alpha", dumb, WithSnowflake(time.Now().Add(-time.Hour), time.Minute))
+	if err := w2.LeaseWorker(leaser, "beta"); err == nil {
+		t.Fatal("LeaseWorker should fail when there is no free worker ID")
+	}
+}